@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+const maxBatchOperations = 100
+
+var errBatchAborted = errors.New("batch aborted")
+
+type batchOperation struct {
+	Op      string          `json:"op"`
+	ID      int64           `json:"id"`
+	Version int32           `json:"version"`
+	Movie   json.RawMessage `json:"movie"`
+}
+
+// batchResult is the outcome of a single operation. Error has the same shape
+// as the "error" of the equivalent single request.
+type batchResult struct {
+	Index  int               `json:"index"`
+	Status int               `json:"status"`
+	Movie  *data.Movie       `json:"movie,omitempty"`
+	Change *data.MovieChange `json:"change,omitempty"`
+	Error  any               `json:"error,omitempty"`
+}
+
+func (res batchResult) failed() bool {
+	return res.Status >= 400
+}
+
+// batchMoviesHandler runs many create, update and delete operations in one
+// request. In atomic mode they all run in one transaction, which is rolled
+// back as soon as one operation fails.
+func (app *application) batchMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Atomic     bool             `json:"atomic"`
+		Operations []batchOperation `json:"operations"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(len(input.Operations) > 0, "operations", "must contain at least 1 operation")
+	v.Check(
+		len(input.Operations) <= maxBatchOperations,
+		"operations",
+		fmt.Sprintf("must not contain more than %d operations", maxBatchOperations),
+	)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	userID := app.contextGetUser(r).ID
+	results := make([]batchResult, len(input.Operations))
+
+	if !input.Atomic {
+		for i, op := range input.Operations {
+			results[i] = app.runBatchOperation(r, app.models.Movies, i, op, userID)
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"results": results}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Movies.WithTx(r.Context(), func(tx data.MovieRepository) error {
+		for i, op := range input.Operations {
+			results[i] = app.runBatchOperation(r, tx, i, op, userID)
+			if results[i].failed() {
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchAborted) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	committed := err == nil
+	if !committed {
+		for i := range results {
+			if !results[i].failed() {
+				results[i] = batchResult{
+					Index:  i,
+					Status: http.StatusFailedDependency,
+					Error:  "the operation was not applied because another operation in the batch failed",
+				}
+			}
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"committed": committed, "results": results}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) runBatchOperation(
+	r *http.Request,
+	movies data.MovieRepository,
+	index int,
+	op batchOperation,
+	userID int64,
+) batchResult {
+	failure := func(status int, message any) batchResult {
+		return batchResult{Index: index, Status: status, Error: message}
+	}
+
+	serverError := func(err error) batchResult {
+		app.logError(r, err)
+		return failure(http.StatusInternalServerError, serverErrorMessage)
+	}
+
+	var movie *data.Movie
+
+	switch op.Op {
+	case "create":
+		status, err := app.newMovieStatus(validator.New(), r, "")
+		if err != nil {
+			return serverError(err)
+		}
+		movie = &data.Movie{Status: status}
+
+	case "update", "delete":
+		v := validator.New()
+		v.Check(op.ID > 0, "id", "must be provided")
+		v.Check(op.Version > 0, "version", "must be provided")
+		if !v.Valid() {
+			return failure(http.StatusUnprocessableEntity, v.Errors)
+		}
+
+		var err error
+		movie, err = movies.Get(r.Context(), op.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				return failure(http.StatusNotFound, notFoundMessage)
+			default:
+				return serverError(err)
+			}
+		}
+
+		if movie.Version != op.Version {
+			return failure(http.StatusConflict, editConflictMessage)
+		}
+
+	default:
+		v := validator.New()
+		v.AddError("op", "must be create, update or delete")
+		return failure(http.StatusUnprocessableEntity, v.Errors)
+	}
+
+	if op.Op == "delete" {
+		canPublish, err := app.hasPermission(r, "movies:publish")
+		if err != nil {
+			return serverError(err)
+		}
+		if !canPublish && movie.Status == data.MovieStatusPublished {
+			return failure(http.StatusForbidden, notPermittedMessage)
+		}
+
+		if err := movies.Delete(r.Context(), movie.ID, movie.Version); err != nil {
+			switch {
+			case errors.Is(err, data.ErrEditConflict):
+				return failure(http.StatusConflict, editConflictMessage)
+			case errors.Is(err, data.ErrRecordNotFound):
+				return failure(http.StatusNotFound, notFoundMessage)
+			default:
+				return serverError(err)
+			}
+		}
+		return batchResult{Index: index, Status: http.StatusOK}
+	}
+
+	if len(op.Movie) == 0 {
+		v := validator.New()
+		v.AddError("movie", "must be provided")
+		return failure(http.StatusUnprocessableEntity, v.Errors)
+	}
+
+	var fields struct {
+		Title   *string       `json:"title"`
+		Year    *int32        `json:"year"`
+		Runtime *data.Runtime `json:"runtime"`
+		Genres  []string      `json:"genres"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(op.Movie))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&fields); err != nil {
+		return failure(http.StatusBadRequest, fmt.Sprintf("movie is not valid: %s", err))
+	}
+
+	if fields.Title != nil {
+		movie.Title = *fields.Title
+	}
+	if fields.Year != nil {
+		movie.Year = *fields.Year
+	}
+	if fields.Runtime != nil {
+		movie.Runtime = *fields.Runtime
+	}
+	if fields.Genres != nil {
+		movie.Genres = fields.Genres
+	}
+
+	v := validator.New()
+	if data.ValidateMovie(v, movie); !v.Valid() {
+		return failure(http.StatusUnprocessableEntity, v.Errors)
+	}
+
+	if op.Op == "create" {
+		if err := movies.Insert(r.Context(), movie, userID); err != nil {
+			return serverError(err)
+		}
+		app.formatMovies(r, movie)
+		return batchResult{Index: index, Status: http.StatusCreated, Movie: movie}
+	}
+
+	change, err := app.saveMovieEdit(r, movies, movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			return failure(http.StatusConflict, editConflictMessage)
+		default:
+			return serverError(err)
+		}
+	}
+
+	if change != nil {
+		change.RuntimeFormat = app.contextGetRuntimeFormat(r)
+		return batchResult{Index: index, Status: http.StatusAccepted, Change: change}
+	}
+
+	app.formatMovies(r, movie)
+	return batchResult{Index: index, Status: http.StatusOK, Movie: movie}
+}
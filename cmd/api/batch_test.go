@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAtomicBatchRollback(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana")
+
+	token := newTestEditor(t, app, true)
+
+	// The second operation fails on a missing movie, the first succeeds on
+	// its own and the third is never run.
+	batch := func(atomic bool) (bool, []int) {
+		t.Helper()
+
+		res := ts.do(t, http.MethodPost, "/api/v1/movies/batch", token, map[string]any{
+			"atomic": atomic,
+			"operations": []map[string]any{
+				{"op": "update", "id": 1, "version": 1, "movie": map[string]any{"title": "Vaiana"}},
+				{"op": "update", "id": 2, "version": 1, "movie": map[string]any{"title": "Coco"}},
+				{"op": "create", "movie": map[string]any{
+					"title": "Encanto", "year": 2021, "runtime": "102 mins", "genres": []string{"animation"},
+				}},
+			},
+		}, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+		}
+
+		var body struct {
+			Committed bool `json:"committed"`
+			Results   []struct {
+				Status int `json:"status"`
+			} `json:"results"`
+		}
+		res.decode(t, &body)
+
+		statuses := []int{}
+		for _, result := range body.Results {
+			statuses = append(statuses, result.Status)
+		}
+		return body.Committed, statuses
+	}
+
+	titles := func() []string {
+		t.Helper()
+
+		res := ts.do(t, http.MethodGet, "/api/v1/movies", token, nil, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+		}
+
+		var body struct {
+			Movies []struct {
+				Title string `json:"title"`
+			} `json:"movies"`
+		}
+		res.decode(t, &body)
+
+		titles := []string{}
+		for _, movie := range body.Movies {
+			titles = append(titles, movie.Title)
+		}
+		return titles
+	}
+
+	committed, statuses := batch(true)
+	if committed {
+		t.Error("expected the atomic batch not to be committed")
+	}
+	expected := []int{http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("atomic statuses does not match (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Moana"}, titles()); diff != "" {
+		t.Errorf("expected the update to be rolled back (-want, +got):\n%s", diff)
+	}
+
+	_, statuses = batch(false)
+	expected = []int{http.StatusOK, http.StatusNotFound, http.StatusCreated}
+	if diff := cmp.Diff(expected, statuses); diff != "" {
+		t.Errorf("statuses does not match (-want, +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"Vaiana", "Encanto"}, titles()); diff != "" {
+		t.Errorf("titles does not match (-want, +got):\n%s", diff)
+	}
+}
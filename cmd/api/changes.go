@@ -0,0 +1,318 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// saveMovieEdit saves the edited movie, unless it is published and the user
+// of the request may not publish, in which case the edit is submitted for
+// review instead. The submitted change is returned.
+func (app *application) saveMovieEdit(
+	r *http.Request,
+	movies data.MovieRepository,
+	movie *data.Movie,
+) (*data.MovieChange, error) {
+	userID := app.contextGetUser(r).ID
+
+	if movie.Status == data.MovieStatusPublished {
+		canPublish, err := app.hasPermission(r, "movies:publish")
+		if err != nil {
+			return nil, err
+		}
+
+		if !canPublish {
+			change := data.NewMovieChange(data.ChangeKindEdit, movie, userID)
+			return change, movies.SubmitChange(r.Context(), change)
+		}
+	}
+
+	return nil, movies.Update(r.Context(), movie, userID)
+}
+
+// movieChangeAcceptedResponse replies that the edit of the movie awaits
+// review.
+func (app *application) movieChangeAcceptedResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	change *data.MovieChange,
+) {
+	change.RuntimeFormat = app.contextGetRuntimeFormat(r)
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/movies/changes/%d", change.ID))
+
+	err := app.writeJSON(w, http.StatusAccepted, envelope{"change": change}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// newMovieStatus returns the status of a movie created by the user of the
+// request, checking the requested one if any. Movies of writers start as
+// drafts, while those of publishers are published right away by default.
+func (app *application) newMovieStatus(v *validator.Validator, r *http.Request, requested string) (string, error) {
+	canPublish, err := app.hasPermission(r, "movies:publish")
+	if err != nil {
+		return "", err
+	}
+
+	if requested == "" {
+		if canPublish {
+			return data.MovieStatusPublished, nil
+		}
+		return data.MovieStatusDraft, nil
+	}
+
+	v.Check(validator.In(requested, data.MovieStatuses...), "status", "unsupported status")
+	v.Check(
+		requested != data.MovieStatusPendingReview,
+		"status",
+		"is set by submitting the movie for review",
+	)
+	v.Check(
+		canPublish || requested == data.MovieStatusDraft,
+		"status",
+		"must be draft without the movies:publish permission",
+	)
+
+	return requested, nil
+}
+
+// submitMovieHandler submits a draft movie for review.
+func (app *application) submitMovieHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	if movie.Status != data.MovieStatusDraft {
+		v := validator.New()
+		v.AddError("status", "only draft movies can be submitted for review")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	change := data.NewMovieChange(data.ChangeKindPublish, movie, app.contextGetUser(r).ID)
+
+	err := app.models.Movies.SubmitChange(r.Context(), change)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.movieChangeAcceptedResponse(w, r, change)
+}
+
+// updateMovieStatusHandler lets publishers set the status of a movie
+// directly, for instance to archive it.
+func (app *application) updateMovieStatusHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	v.Check(validator.In(input.Status, data.MovieStatuses...), "status", "unsupported status")
+	v.Check(
+		input.Status != data.MovieStatusPendingReview,
+		"status",
+		"is set by submitting the movie for review",
+	)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.Movies.SetStatus(r.Context(), movie, input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.formatMovies(r, movie)
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listMovieChangesHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		filters data.Filters
+		err     error
+	)
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	status := qs.GetString("status", data.ChangeStatusPending)
+	v.Check(
+		status == "all" || validator.In(status, data.ChangeStatuses...),
+		"status",
+		"must be pending, approved, rejected or all",
+	)
+	if status == "all" {
+		status = ""
+	}
+
+	if filters.Page, err = qs.GetInt("page", defaultPageNum); err != nil {
+		v.AddError("page", "invalid query param, must be integer")
+	}
+	if filters.PageSize, err = qs.GetInt("page_size", defaultPageSize); err != nil {
+		v.AddError("page_size", "invalid query param, must be integer")
+	}
+	filters.Sort = qs.GetString("sort", "id")
+	filters.SortSafelist = []string{"id", "-id"}
+
+	if filters.Validate(v); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	changes, meta, err := app.models.MovieChanges.GetAll(r.Context(), status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for _, change := range changes {
+		change.RuntimeFormat = app.contextGetRuntimeFormat(r)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"changes": changes, "metadata": meta}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	change, err := app.models.MovieChanges.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	change.RuntimeFormat = app.contextGetRuntimeFormat(r)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"change": change}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) approveMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewMovieChange(w, r, true)
+}
+
+func (app *application) rejectMovieChangeHandler(w http.ResponseWriter, r *http.Request) {
+	app.reviewMovieChange(w, r, false)
+}
+
+// reviewMovieChange approves or rejects the change with the comment of the
+// request, which is required to reject it.
+func (app *application) reviewMovieChange(w http.ResponseWriter, r *http.Request, approve bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Comment string `json:"comment"`
+	}
+
+	if err = app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	input.Comment = strings.TrimSpace(input.Comment)
+
+	v := validator.New()
+	v.Check(approve || input.Comment != "", "comment", "must be provided to reject a change")
+	v.Check(len(input.Comment) <= 2000, "comment", "must not be longer than 2000 bytes")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var (
+		reviewerID = app.contextGetUser(r).ID
+		change     *data.MovieChange
+		movie      *data.Movie
+	)
+
+	if approve {
+		change, movie, err = app.models.MovieChanges.Approve(r.Context(), id, reviewerID, input.Comment)
+	} else {
+		change, err = app.models.MovieChanges.Reject(r.Context(), id, reviewerID, input.Comment)
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, data.ErrChangeReviewed):
+			app.errorResponse(w, r, http.StatusConflict, "the change has already been reviewed")
+		case errors.Is(err, data.ErrEditConflict):
+			app.errorResponse(w, r, http.StatusConflict, "the movie was modified after the change was made, please reject it")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	change.RuntimeFormat = app.contextGetRuntimeFormat(r)
+	env := envelope{"change": change}
+
+	if movie != nil {
+		app.formatMovies(r, movie)
+		env["movie"] = movie
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+// movieETag returns the strong entity tag of a movie, which changes with
+// every new version and whenever its images change.
+func movieETag(movie *data.Movie) string {
+	return `"` + movieTag(movie) + `"`
+}
+
+// projectedMovieETag returns the strong entity tag of the movie restricted to
+// the projection, which is that of the whole movie when every field is
+// selected.
+func projectedMovieETag(movie *data.Movie, p data.Projection) string {
+	if len(p.Fields) == 0 {
+		return movieETag(movie)
+	}
+
+	fields, include := slices.Clone(p.Fields), slices.Clone(p.Include)
+	slices.Sort(fields)
+	slices.Sort(include)
+
+	hash := fnv.New32a()
+	fmt.Fprintf(hash, "%s;%s", strings.Join(fields, ","), strings.Join(include, ","))
+
+	return fmt.Sprintf(`"%s-%08x"`, movieTag(movie), hash.Sum32())
+}
+
+// localizedETag returns the entity tag of a representation localized to the
+// locale, which is the given one when nothing was localized.
+func localizedETag(etag, locale string) string {
+	if locale == "" {
+		return etag
+	}
+
+	return strings.TrimSuffix(etag, `"`) + "-" + locale + `"`
+}
+
+// movieTag identifies the representation of a movie. Changing its status,
+// images, alternate titles, releases, external ids or tags does not create
+// a new version, so they are added in, as is the format of its runtime.
+func movieTag(movie *data.Movie) string {
+	tag := fmt.Sprintf("%d-%d", movie.ID, movie.Version)
+
+	if movie.Status != data.MovieStatusPublished {
+		tag += "-" + movie.Status
+	}
+	if movie.RuntimeFormat != "" && movie.RuntimeFormat != data.RuntimeFormatMins {
+		tag += "-" + movie.RuntimeFormat
+	}
+
+	if len(movie.Images) == 0 &&
+		len(movie.Titles) == 0 &&
+		len(movie.Releases) == 0 &&
+		len(movie.ExternalIDs) == 0 &&
+		len(movie.Tags) == 0 {
+		return tag
+	}
+
+	hash := fnv.New32a()
+	json.NewEncoder(hash).Encode(
+		[]any{movie.Images, movie.Titles, movie.Releases, movie.ExternalIDs, movie.Tags},
+	)
+
+	return fmt.Sprintf("%s-%08x", tag, hash.Sum32())
+}
+
+// moviesETag returns a strong entity tag for a listing, derived from the
+// tags and titles of the listed movies, as titles depend on the language,
+// and the rest of the response, such as the pagination metadata.
+func moviesETag(movies []*data.Movie, rest ...any) (string, error) {
+	hash := sha256.New()
+
+	for _, movie := range movies {
+		fmt.Fprintf(hash, "%s:%s,", movieTag(movie), movie.Title)
+	}
+
+	for _, value := range rest {
+		js, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(js)
+	}
+
+	return fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16]), nil
+}
+
+// etagMatches reports whether the etag is listed in an If-Match or
+// If-None-Match header value. Weak tags only match when weak is true.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notModified replies with 304 Not Modified when the request's If-None-Match
+// header matches the etag, reporting whether it did.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag, true) {
+		return false
+	}
+
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// preconditionMet checks the request's If-Match header against the etag of
+// the resource about to be changed. When the header is missing and required
+// by the configuration, or does not match, it sends the error response and
+// returns false.
+func (app *application) preconditionMet(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-Match")
+
+	if header == "" {
+		if app.config.conditional.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(header, etag, false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+
+	return true
+}
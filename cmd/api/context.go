@@ -9,7 +9,11 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey          = contextKey("user")
+	permissionsContextKey   = contextKey("permissions")
+	runtimeFormatContextKey = contextKey("runtimeFormat")
+)
 
 func (*application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -22,3 +26,27 @@ func (*application) contextGetUser(r *http.Request) *data.User {
 	}
 	panic("missing user value in request context")
 }
+
+func (*application) contextSetPermissions(r *http.Request, permissions data.Permissions) *http.Request {
+	ctx := context.WithValue(r.Context(), permissionsContextKey, permissions)
+	return r.WithContext(ctx)
+}
+
+func (*application) contextGetPermissions(r *http.Request) (data.Permissions, bool) {
+	permissions, ok := r.Context().Value(permissionsContextKey).(data.Permissions)
+	return permissions, ok
+}
+
+func (*application) contextSetRuntimeFormat(r *http.Request, format string) *http.Request {
+	ctx := context.WithValue(r.Context(), runtimeFormatContextKey, format)
+	return r.WithContext(ctx)
+}
+
+// contextGetRuntimeFormat returns the runtime format negotiated for the
+// request, which is the default one when there was none.
+func (*application) contextGetRuntimeFormat(r *http.Request) string {
+	if format, ok := r.Context().Value(runtimeFormatContextKey).(string); ok {
+		return format
+	}
+	return data.RuntimeFormatMins
+}
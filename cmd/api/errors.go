@@ -1,10 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 )
 
+const (
+	notFoundMessage     = "the requested resource could not be found"
+	editConflictMessage = "unable to update the record due to an edit conflit, please try again"
+	serverErrorMessage  = "the server encountered a problem and could not process your request"
+	notPermittedMessage = "your user account does not have the necessary permissions to access this resource"
+)
+
+// statusClientClosedRequest is the non-standard status, borrowed from nginx,
+// of the requests the client went away from before they were answered.
+const statusClientClosedRequest = 499
+
 func (app *application) logError(r *http.Request, err error) {
 	app.logger.PrintError(err, map[string]string{
 		"request_method": r.Method,
@@ -28,15 +41,29 @@ func (app *application) errorResponse(
 	}
 }
 
+// serverErrorResponse reports an unexpected error. Once the client cancelled
+// its request, the error is most likely a query that got cancelled along, so
+// it is only logged, as nobody is left to read the response. The driver does
+// not always return context.Canceled for such queries, hence the check on
+// the request context rather than on the error.
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(r.Context().Err(), context.Canceled) {
+		app.logger.PrintInfo("client closed request", map[string]string{
+			"request_method": r.Method,
+			"request_url":    r.URL.String(),
+			"status":         fmt.Sprint(statusClientClosedRequest),
+			"error":          err.Error(),
+		})
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+
 	app.logError(r, err)
-	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, serverErrorMessage)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
-	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, notFoundMessage)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +75,11 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 }
 
+func (app *application) unsupportedMediaTypeResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %q content type is not supported for this resource", r.Header.Get("Content-Type"))
+	app.errorResponse(w, r, http.StatusUnsupportedMediaType, message)
+}
+
 func (app *application) failedValidationResponse(
 	w http.ResponseWriter,
 	r *http.Request,
@@ -57,8 +89,36 @@ func (app *application) failedValidationResponse(
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
-	msg := "unable to update the record due to an edit conflit, please try again"
-	app.errorResponse(w, r, http.StatusConflict, msg)
+	app.errorResponse(w, r, http.StatusConflict, editConflictMessage)
+}
+
+// duplicateMovieResponse reports that the movie conflicts with an existing
+// one, giving its id so that clients can use it instead.
+func (app *application) duplicateMovieResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	message string,
+	existingID int64,
+) {
+	err := app.writeJSON(w, http.StatusConflict, envelope{"error": message, "existing_movie_id": existingID}, nil)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(500)
+	}
+}
+
+func (app *application) patchConflictResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.errorResponse(w, r, http.StatusConflict, err.Error())
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has changed since it was last retrieved, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must be conditional, please provide an If-Match header"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, message)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
@@ -88,6 +148,5 @@ func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.R
 }
 
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
-	message := "your user account does not have the necessary permissions to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, notPermittedMessage)
 }
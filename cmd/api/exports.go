@@ -0,0 +1,230 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+const exportWriteTimeout = 10 * time.Minute
+
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+	"json":   "application/json",
+}
+
+// movieExporter writes movies one at a time in an export format.
+type movieExporter interface {
+	Write(movie *data.Movie) error
+	Close() error
+}
+
+// exportMoviesHandler streams the movies matching the title and genres
+// filters straight from the database to the client.
+func (app *application) exportMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var q data.MovieQuery
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	q.Title = qs.GetString("title", "")
+	q.Genres = qs.GetCSV("genres", []string{})
+	format := qs.GetString("format", "json")
+
+	v.Check(validator.In(format, "csv", "ndjson", "json"), "format", "must be csv, ndjson or json")
+
+	publishedOnly, err := app.readsPublishedOnly(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if publishedOnly {
+		q.Statuses = []string{data.MovieStatusPublished}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The export may take longer than the server write timeout.
+	err = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="movies.%s"`, format))
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	// Until something is written, an error can still be sent as such.
+	tw := &trackingWriter{w: w}
+
+	var (
+		out io.Writer = tw
+		gz  *gzip.Writer
+	)
+	if acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+
+		gz = gzip.NewWriter(tw)
+		out = gz
+	}
+
+	runtimeFormat := app.contextGetRuntimeFormat(r)
+
+	var exporter movieExporter
+	switch format {
+	case "csv":
+		exporter = newCSVMovieExporter(out)
+	case "ndjson":
+		exporter = &jsonMovieExporter{out: out, runtimeFormat: runtimeFormat, separator: "\n", end: "\n"}
+	default:
+		exporter = &jsonMovieExporter{
+			out: out, runtimeFormat: runtimeFormat, start: `{"movies":[`, separator: ",", end: "]}\n",
+		}
+	}
+
+	err = app.models.Movies.Stream(r.Context(), q, exporter.Write)
+	if err == nil {
+		err = exporter.Close()
+	}
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	if !tw.written {
+		w.Header().Del("Content-Encoding")
+		w.Header().Del("Content-Disposition")
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Once the first movie is written the status can no longer change, so
+	// errors are logged and the response is left truncated.
+	app.logError(r, err)
+}
+
+// trackingWriter records whether anything was written through it.
+type trackingWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = t.written || len(p) > 0
+	return t.w.Write(p)
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(encoding, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+
+		quality, err := headerQuality(params)
+		return err == nil && quality > 0
+	}
+	return false
+}
+
+type csvMovieExporter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func newCSVMovieExporter(out io.Writer) *csvMovieExporter {
+	return &csvMovieExporter{writer: csv.NewWriter(out)}
+}
+
+func (e *csvMovieExporter) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.writer.Write([]string{"id", "title", "year", "runtime", "genres", "version"})
+}
+
+func (e *csvMovieExporter) Write(movie *data.Movie) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.writer.Write([]string{
+		strconv.FormatInt(movie.ID, 10),
+		movie.Title,
+		strconv.FormatInt(int64(movie.Year), 10),
+		strconv.FormatInt(int64(movie.Runtime), 10),
+		strings.Join(movie.Genres, ","),
+		strconv.FormatInt(int64(movie.Version), 10),
+	})
+}
+
+func (e *csvMovieExporter) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+// jsonMovieExporter writes movies as JSON values between start and end,
+// separated by separator, with their runtime in the given format.
+type jsonMovieExporter struct {
+	out           io.Writer
+	runtimeFormat string
+	start         string
+	separator     string
+	end           string
+	count         int
+}
+
+func (e *jsonMovieExporter) Write(movie *data.Movie) error {
+	movie.RuntimeFormat = e.runtimeFormat
+
+	js, err := json.Marshal(movie)
+	if err != nil {
+		return err
+	}
+
+	prefix := e.separator
+	if e.count == 0 {
+		prefix = e.start
+	}
+	e.count++
+
+	if _, err = io.WriteString(e.out, prefix); err != nil {
+		return err
+	}
+	_, err = e.out.Write(js)
+	return err
+}
+
+func (e *jsonMovieExporter) Close() error {
+	end := e.end
+	if e.count == 0 {
+		end = e.start + e.end
+		if e.start == "" {
+			end = ""
+		}
+	}
+
+	_, err := io.WriteString(e.out, end)
+	return err
+}
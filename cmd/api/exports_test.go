@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+// failingStreamMovies fails to stream movies before returning any of them.
+type failingStreamMovies struct {
+	data.MovieRepository
+}
+
+func (failingStreamMovies) Stream(ctx context.Context, q data.MovieQuery, fn func(movie *data.Movie) error) error {
+	return errors.New("query timed out")
+}
+
+func TestExportMoviesHandlerStreamError(t *testing.T) {
+	tests := []struct {
+		desc           string
+		acceptEncoding string
+	}{
+		{desc: "identity", acceptEncoding: "identity"},
+		{desc: "gzip", acceptEncoding: "gzip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.models.Movies = failingStreamMovies{app.models.Movies}
+			ts := newTestServer(t, app.routes())
+
+			token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+			res := ts.do(t, http.MethodGet, "/api/v1/movies/export?format=csv", token, nil, http.Header{
+				"Accept-Encoding": {tt.acceptEncoding},
+			})
+			if res.status != http.StatusInternalServerError {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, res.status, res.body)
+			}
+
+			if got := res.headers.Get("Content-Encoding"); got != "" {
+				t.Errorf("expected no content encoding, got %q", got)
+			}
+			if got := res.headers.Get("Content-Type"); got != "application/json" {
+				t.Errorf("expected content type %q, got %q", "application/json", got)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{header: "", expected: false},
+		{header: "gzip", expected: true},
+		{header: "deflate, gzip;q=0.5", expected: true},
+		{header: "gzip;q=0", expected: false},
+		{header: "gzip; q=0.0", expected: false},
+		{header: "gzip;q=0.000", expected: false},
+		{header: "gzip;Q=1.0", expected: true},
+		{header: "gzip;q=invalid", expected: false},
+		{header: "br", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.header)
+
+			if got := acceptsGzip(r); got != tt.expected {
+				t.Errorf("expected %t, got %t", tt.expected, got)
+			}
+		})
+	}
+}
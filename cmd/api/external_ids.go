@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func (app *application) showMovieByExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	externalID := data.ExternalID{Source: chi.URLParam(r, "source"), ID: chi.URLParam(r, "id")}
+
+	movie, err := app.models.Movies.GetByExternalID(r.Context(), externalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.showMovie(w, r, movie)
+}
+
+func (app *application) addMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var input data.ExternalID
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateExternalID(v, "id", input); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err := app.models.ExternalIDs.Insert(r.Context(), movie.ID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			app.duplicateExternalIDResponse(w, r, []data.ExternalID{input})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"external_id": input}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieExternalIDHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	externalID := data.ExternalID{Source: chi.URLParam(r, "source"), ID: chi.URLParam(r, "externalID")}
+
+	err := app.models.ExternalIDs.Delete(r.Context(), movie.ID, externalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "external id successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// duplicateExternalIDResponse reports the movie that one of the external ids
+// already belongs to.
+func (app *application) duplicateExternalIDResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	externalIDs []data.ExternalID,
+) {
+	for _, externalID := range externalIDs {
+		existing, err := app.models.Movies.GetByExternalID(r.Context(), externalID)
+		if err == nil {
+			message := "the " + externalID.Source + " id " + externalID.ID + " already belongs to a movie"
+			app.duplicateMovieResponse(w, r, message, existing.ID)
+			return
+		}
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	// The movie owning the id is in the trash.
+	app.errorResponse(w, r, http.StatusConflict, "an external id already belongs to a deleted movie")
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/data"
 )
 
 type envelope map[string]interface{}
@@ -22,6 +24,14 @@ func (*application) readIDParam(r *http.Request) (int64, error) {
 	return 0, errors.New("invalid id parameter")
 }
 
+func (*application) readVersionParam(r *http.Request) (int32, error) {
+	versionParam := chi.URLParam(r, "version")
+	if version, err := strconv.ParseInt(versionParam, 10, 32); err == nil {
+		return int32(version), nil
+	}
+	return 0, errors.New("invalid version parameter")
+}
+
 func (*application) writeJSON(
 	w http.ResponseWriter,
 	status int,
@@ -43,6 +53,15 @@ func (*application) writeJSON(
 	return nil
 }
 
+// formatMovies sets the runtime format negotiated for the request on the
+// movies, so that they are written, and tagged, in that format.
+func (app *application) formatMovies(r *http.Request, movies ...*data.Movie) {
+	format := app.contextGetRuntimeFormat(r)
+	for _, movie := range movies {
+		movie.RuntimeFormat = format
+	}
+}
+
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, dest any) error {
 	max_bytes := 1_048_567
 	r.Body = http.MaxBytesReader(w, r.Body, int64(max_bytes))
@@ -116,3 +135,15 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// headerQuality returns the weight given by the parameters of an element of
+// an Accept-* header, such as ";q=0.5", which defaults to 1.
+func headerQuality(params string) (float64, error) {
+	for _, param := range strings.Split(params, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(strings.TrimSpace(key), "q") {
+			return strconv.ParseFloat(strings.TrimSpace(value), 64)
+		}
+	}
+	return 1, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/images"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// maxImagePixels refuses images that would take too much memory to decode,
+// whatever their compressed size.
+const maxImagePixels = 50_000_000
+
+// thumbnailSizes gives the box each kind of image is scaled down to fit in.
+var thumbnailSizes = map[string][2]int{
+	data.ImageKindPoster:   {342, 513},
+	data.ImageKindBackdrop: {780, 439},
+}
+
+// uploadMovieImageHandler stores a poster or backdrop of the movie from the
+// "image" file of a multipart form, along with a thumbnail, replacing the
+// previous image of the same kind.
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	// Leave room for the multipart headers and the other form fields.
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.images.maxBytes+64*1024)
+
+	err := r.ParseMultipartForm(1024 * 1024)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("image must not be larger than %d bytes", app.config.images.maxBytes))
+		case errors.Is(err, http.ErrNotMultipart):
+			app.unsupportedMediaTypeResponse(w, r)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	v := validator.New()
+
+	kind := r.FormValue("kind")
+	v.Check(kind != "", "kind", "must be provided")
+	v.Check(kind == "" || validator.In(kind, data.ImageKinds...), "kind", "must be poster or backdrop")
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		v.AddError("image", "must be provided")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+	defer file.Close()
+
+	v.Check(
+		header.Size <= app.config.images.maxBytes,
+		"image",
+		fmt.Sprintf("must not be larger than %d bytes", app.config.images.maxBytes),
+	)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	original, err := io.ReadAll(file)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	img, contentType, err := images.Decode(original, maxImagePixels)
+	if err != nil {
+		switch {
+		case errors.Is(err, images.ErrUnsupportedFormat):
+			v.AddError("image", "must be a JPEG, PNG or GIF image")
+		case errors.Is(err, images.ErrTooLarge):
+			v.AddError("image", fmt.Sprintf("must not have more than %d pixels", maxImagePixels))
+		default:
+			v.AddError("image", "is not a valid image")
+		}
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	size := thumbnailSizes[kind]
+	thumbnail, thumbnailType, err := encodeThumbnail(images.Thumbnail(img, size[0], size[1]), contentType)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	key, err := imageKey(movie.ID, kind)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	movieImage := &data.MovieImage{
+		Kind:         kind,
+		Key:          key + extension(contentType),
+		ThumbnailKey: key + "-thumb" + extension(thumbnailType),
+		ContentType:  contentType,
+		Width:        img.Bounds().Dx(),
+		Height:       img.Bounds().Dy(),
+		Size:         int64(len(original)),
+	}
+	movieImage.URL = app.storage.URL(movieImage.Key)
+	movieImage.ThumbnailURL = app.storage.URL(movieImage.ThumbnailKey)
+
+	if err = app.storage.Put(r.Context(), movieImage.Key, bytes.NewReader(original), contentType); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.storage.Put(r.Context(), movieImage.ThumbnailKey, bytes.NewReader(thumbnail), thumbnailType)
+	if err != nil {
+		app.deleteStoredImages(movieImage.Key)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	replaced, err := app.models.MovieImages.Upsert(r.Context(), movie.ID, movieImage)
+	if err != nil {
+		app.deleteStoredImages(movieImage.Key, movieImage.ThumbnailKey)
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.deleteStoredImages(replaced...)
+
+	headers := make(http.Header)
+	headers.Set("Location", movieImage.URL)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": movieImage}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	keys, err := app.models.MovieImages.Delete(r.Context(), movie.ID, chi.URLParam(r, "kind"))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteStoredImages(keys...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteStoredImages removes files that are no longer referenced. Failures
+// are only logged, as they leave nothing worse than an orphaned file.
+func (app *application) deleteStoredImages(keys ...string) {
+	for _, key := range keys {
+		if err := app.storage.Delete(context.Background(), key); err != nil {
+			app.logger.PrintError(err, map[string]string{"key": key})
+		}
+	}
+}
+
+// encodeThumbnail encodes photographs as JPEG, and everything else as PNG to
+// keep its transparency.
+func encodeThumbnail(img image.Image, sourceType string) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	if sourceType == "image/jpeg" {
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+		return buf.Bytes(), "image/jpeg", err
+	}
+
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), "image/png", err
+}
+
+// imageKey returns a new storage key, without extension, for an image of the
+// movie. Every upload gets a random key, so that the files can be cached
+// forever.
+func imageKey(movieID int64, kind string) (string, error) {
+	random := make([]byte, 8)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("movies/%d/%s-%s", movieID, kind, hex.EncodeToString(random)), nil
+}
+
+func extension(contentType string) string {
+	return "." + strings.TrimPrefix(contentType, "image/")
+}
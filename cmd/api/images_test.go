@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestMovieImagesUpdatePermissions(t *testing.T) {
+	tests := []struct {
+		desc           string
+		publisher      bool
+		urlPath        string
+		expectedStatus int
+	}{
+		{"writer deletes image of published", false, "/1/images/poster", http.StatusForbidden},
+		{"writer deletes image of draft", false, "/2/images/poster", http.StatusOK},
+		{"publisher deletes image of published", true, "/1/images/poster", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app, ts := newTestEnv(t, "Moana", "Untitled")
+			token := newTestEditor(t, app, tt.publisher)
+
+			for _, movieID := range []int64{1, 2} {
+				_, err := app.models.MovieImages.Upsert(context.Background(), movieID, &data.MovieImage{
+					Kind:         "poster",
+					ContentType:  "image/png",
+					Key:          fmt.Sprintf("movies/%d/poster.png", movieID),
+					ThumbnailKey: fmt.Sprintf("movies/%d/poster_thumb.png", movieID),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res := ts.do(t, http.MethodDelete, "/api/v1/movies"+tt.urlPath, token, nil, nil)
+			if res.status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, res.status, res.body)
+			}
+		})
+	}
+}
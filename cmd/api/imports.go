@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+const importBatchSize = 500
+
+// importFormats maps the media types accepted for uploads to their format.
+var importFormats = map[string]string{
+	"text/csv":             "csv",
+	"application/x-ndjson": "ndjson",
+	"application/ndjson":   "ndjson",
+}
+
+var errInvalidImport = errors.New("invalid import")
+
+func (app *application) importMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	format, ok := importFormats[mediaType]
+	if !ok {
+		app.unsupportedMediaTypeResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+
+	async, err := NewQueryParams(r).GetBool("async", false)
+	if err != nil {
+		v.AddError("async", "invalid query param, must be boolean")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.imports.maxBytes)
+	user := app.contextGetUser(r)
+
+	status, err := app.newMovieStatus(v, r, "")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Uploads of unknown or large size are processed in the background, so
+	// that the client is not kept waiting.
+	if async || r.ContentLength < 0 || r.ContentLength > app.config.imports.syncMaxBytes {
+		app.startImportJob(w, r, format, status, user.ID)
+		return
+	}
+
+	report, err := app.importMovies(r.Context(), format, r.Body, status, user.ID, nil)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		case errors.Is(err, errInvalidImport):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, err.Error())
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"report": report}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// startImportJob saves the upload to a temporary file and imports it in the
+// background, replying with the job to poll for its status.
+func (app *application) startImportJob(
+	w http.ResponseWriter,
+	r *http.Request,
+	format string,
+	status string,
+	userID int64,
+) {
+	file, err := os.CreateTemp("", "greenlight-import-*")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	if _, err = io.Copy(file, r.Body); err != nil {
+		cleanup()
+
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.badRequestResponse(w, r, fmt.Errorf("body must not be larger than %d bytes", maxBytesError.Limit))
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	job := &data.ImportJob{
+		UserID: userID,
+		Format: format,
+		Status: data.ImportStatusPending,
+		Report: data.ImportReport{Errors: []data.ImportRowError{}},
+	}
+
+	if err = app.models.ImportJobs.Insert(r.Context(), job); err != nil {
+		cleanup()
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The background function works on its own copy of the job, leaving the
+	// one in the response untouched.
+	bgJob := *job
+
+	app.background(func() {
+		defer cleanup()
+		app.runImportJob(context.Background(), &bgJob, status, file)
+	})
+
+	headers := make(http.Header)
+	headers.Set("Location", fmt.Sprintf("/api/v1/movies/import/%d", job.ID))
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"job": job}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) runImportJob(
+	ctx context.Context,
+	job *data.ImportJob,
+	status string,
+	body io.Reader,
+) {
+	job.Status = data.ImportStatusRunning
+	if err := app.models.ImportJobs.Update(ctx, job); err != nil {
+		app.logger.PrintError(err, nil)
+		return
+	}
+
+	progress := func(report *data.ImportReport) error {
+		job.Report = *report
+		return app.models.ImportJobs.Update(ctx, job)
+	}
+
+	report, err := app.importMovies(ctx, job.Format, body, status, job.UserID, progress)
+	job.Report = *report
+	job.Status = data.ImportStatusCompleted
+
+	if err != nil {
+		job.Status = data.ImportStatusFailed
+		job.Error = err.Error()
+
+		if !errors.Is(err, errInvalidImport) {
+			app.logger.PrintError(err, map[string]string{"import_job": strconv.FormatInt(job.ID, 10)})
+			job.Error = "the import could not be completed"
+		}
+	}
+
+	if err = app.models.ImportJobs.Update(ctx, job); err != nil {
+		app.logger.PrintError(err, nil)
+	}
+}
+
+func (app *application) showImportJobHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	job, err := app.models.ImportJobs.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if job.UserID != app.contextGetUser(r).ID {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"job": job}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importMovies validates every row of the upload and inserts the valid ones
+// in batches, with the given status. The report is passed to progress after
+// each batch. Batches inserted before an error are kept.
+func (app *application) importMovies(
+	ctx context.Context,
+	format string,
+	body io.Reader,
+	status string,
+	userID int64,
+	progress func(report *data.ImportReport) error,
+) (*data.ImportReport, error) {
+	report := &data.ImportReport{Errors: []data.ImportRowError{}}
+	batch := make([]*data.Movie, 0, importBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := app.models.Movies.InsertBatch(ctx, batch, userID); err != nil {
+			return err
+		}
+
+		report.Imported += len(batch)
+		batch = batch[:0]
+
+		if progress != nil {
+			return progress(report)
+		}
+		return nil
+	}
+
+	err := readMovieRows(format, body, func(line int, movie *data.Movie, rowErrors map[string]string) error {
+		report.Total++
+
+		if rowErrors == nil {
+			v := validator.New()
+			if data.ValidateMovie(v, movie); !v.Valid() {
+				rowErrors = v.Errors
+			}
+		}
+
+		if rowErrors != nil {
+			report.AddError(line, rowErrors)
+			return nil
+		}
+
+		movie.Status = status
+		batch = append(batch, movie)
+		if len(batch) == importBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, flush()
+}
+
+type movieRowFunc func(line int, movie *data.Movie, rowErrors map[string]string) error
+
+// readMovieRows decodes the upload one row at a time, passing fn either the
+// movie or the errors that prevented decoding it.
+func readMovieRows(format string, body io.Reader, fn movieRowFunc) error {
+	switch format {
+	case "csv":
+		return readCSVMovieRows(body, fn)
+	case "ndjson":
+		return readNDJSONMovieRows(body, fn)
+	default:
+		panic("unsupported import format " + format)
+	}
+}
+
+func readCSVMovieRows(body io.Reader, fn movieRowFunc) error {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%w: unable to read the CSV header", errInvalidImport)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !validator.In(name, "title", "year", "runtime", "genres") {
+			return fmt.Errorf("%w: unknown column %q", errInvalidImport, name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range []string{"title", "year", "runtime", "genres"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%w: missing column %q", errInvalidImport, name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		// A row with the wrong number of fields is reported like any invalid
+		// row, but a syntax error, such as an unterminated quote, leaves the
+		// rest of the upload unreadable.
+		var parseError *csv.ParseError
+		if errors.As(err, &parseError) {
+			if !errors.Is(parseError.Err, csv.ErrFieldCount) {
+				return fmt.Errorf("%w: line %d: %s", errInvalidImport, parseError.Line, parseError.Err)
+			}
+			if err = fn(parseError.Line, nil, map[string]string{"row": parseError.Err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		movie := &data.Movie{
+			Title:  strings.TrimSpace(record[columns["title"]]),
+			Genres: []string{},
+		}
+		rowErrors := make(map[string]string)
+
+		year, err := strconv.ParseInt(strings.TrimSpace(record[columns["year"]]), 10, 32)
+		if err != nil {
+			rowErrors["year"] = "must be an integer"
+		}
+		movie.Year = int32(year)
+
+		movie.Runtime, err = data.ParseRuntime(record[columns["runtime"]])
+		if err != nil {
+			rowErrors["runtime"] = "must be a number of minutes or a duration such as 1h 42m"
+		}
+
+		for _, genre := range strings.Split(record[columns["genres"]], ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				movie.Genres = append(movie.Genres, genre)
+			}
+		}
+
+		if len(rowErrors) > 0 {
+			err = fn(line, nil, rowErrors)
+		} else {
+			err = fn(line, movie, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func readNDJSONMovieRows(body io.Reader, fn movieRowFunc) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1_048_576)
+
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		var input struct {
+			Title   string       `json:"title"`
+			Year    int32        `json:"year"`
+			Runtime data.Runtime `json:"runtime"`
+			Genres  []string     `json:"genres"`
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+
+		var err error
+		if err = decoder.Decode(&input); err != nil {
+			err = fn(line, nil, map[string]string{"row": err.Error()})
+		} else {
+			err = fn(line, &data.Movie{
+				Title:   input.Title,
+				Year:    input.Year,
+				Runtime: input.Runtime,
+				Genres:  input.Genres,
+			}, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("%w: lines must not be longer than 1048576 bytes", errInvalidImport)
+		}
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestImportMalformedCSV(t *testing.T) {
+	upload := []byte("title,year,runtime,genres\nab\"c,1,2,x\n")
+	csvHeaders := http.Header{"Content-Type": {"text/csv"}}
+
+	t.Run("sync", func(t *testing.T) {
+		app, ts := newTestEnv(t)
+		token := newTestEditor(t, app, false)
+
+		res := ts.do(t, http.MethodPost, "/api/v1/movies/import", token, upload, csvHeaders)
+		if res.status != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.status, res.body)
+		}
+
+		var body struct {
+			Error string `json:"error"`
+		}
+		res.decode(t, &body)
+
+		if !strings.Contains(body.Error, "line 2") {
+			t.Errorf("expected the error to name line 2, got %q", body.Error)
+		}
+	})
+
+	t.Run("async", func(t *testing.T) {
+		app, ts := newTestEnv(t)
+		token := newTestEditor(t, app, false)
+
+		res := ts.do(t, http.MethodPost, "/api/v1/movies/import?async=true", token, upload, csvHeaders)
+		if res.status != http.StatusAccepted {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusAccepted, res.status, res.body)
+		}
+
+		var body struct {
+			Job data.ImportJob `json:"job"`
+		}
+		res.decode(t, &body)
+
+		app.wg.Wait()
+
+		res = ts.do(t, http.MethodGet, fmt.Sprintf("/api/v1/movies/import/%d", body.Job.ID), token, nil, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+		}
+		res.decode(t, &body)
+
+		if body.Job.Status != data.ImportStatusFailed || !strings.Contains(body.Job.Error, "line 2") {
+			t.Errorf("expected the job to fail on line 2, got %+v", body.Job)
+		}
+	})
+}
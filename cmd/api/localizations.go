@@ -0,0 +1,239 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// acceptedLocales returns the locales of the request's Accept-Language
+// header, most preferred first. Each tag is followed by its more general
+// prefixes, so that "fr-CA" also accepts titles in "fr".
+func acceptedLocales(r *http.Request) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = data.NormalizeLocale(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality, err := headerQuality(params)
+		if err == nil && quality > 0 {
+			languages = append(languages, language{tag, quality})
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].quality > languages[j].quality
+	})
+
+	var locales []string
+	seen := make(map[string]bool)
+
+	for _, lang := range languages {
+		for tag := lang.tag; tag != ""; {
+			if !seen[tag] && data.LocaleRX.MatchString(tag) {
+				seen[tag] = true
+				locales = append(locales, tag)
+			}
+
+			i := strings.LastIndex(tag, "-")
+			if i < 0 {
+				break
+			}
+			tag = tag[:i]
+		}
+	}
+
+	return locales
+}
+
+func (app *application) putMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title string `json:"title"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	title := &data.MovieTitle{
+		Locale: data.NormalizeLocale(chi.URLParam(r, "locale")),
+		Title:  strings.TrimSpace(input.Title),
+	}
+
+	v := validator.New()
+	if data.ValidateMovieTitle(v, title); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.MovieTitles.Upsert(r.Context(), movie.ID, title); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"title": title}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTitleHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.MovieTitles.Delete(r.Context(), movie.ID, data.NormalizeLocale(chi.URLParam(r, "locale")))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "title successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ReleaseDate   string `json:"release_date"`
+		Certification string `json:"certification"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	release := &data.MovieRelease{
+		Country:       strings.ToUpper(chi.URLParam(r, "country")),
+		ReleaseDate:   input.ReleaseDate,
+		Certification: strings.TrimSpace(input.Certification),
+	}
+
+	v := validator.New()
+	if data.ValidateMovieRelease(v, release); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.MovieReleases.Upsert(r.Context(), movie.ID, release); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"release": release}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.MovieReleases.Delete(r.Context(), movie.ID, strings.ToUpper(chi.URLParam(r, "country")))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "release successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getMovieForUpdate fetches the movie of the request's id parameter, whose
+// children are about to be changed, checking the If-Match header against
+// it. It sends the error response and returns false when it fails.
+func (app *application) getMovieForUpdate(w http.ResponseWriter, r *http.Request) (*data.Movie, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	app.formatMovies(r, movie)
+	if !app.preconditionMet(w, r, movieETag(movie)) {
+		return nil, false
+	}
+
+	return movie, true
+}
+
+// getMovieChildrenForUpdate is getMovieForUpdate for changes to the alternate
+// titles, releases, external ids, images or tags of the movie. They are not
+// part of the changes submitted for review, so once the movie is published
+// only publishers may change them.
+func (app *application) getMovieChildrenForUpdate(
+	w http.ResponseWriter,
+	r *http.Request,
+) (*data.Movie, bool) {
+	movie, ok := app.getMovieForUpdate(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if movie.Status == data.MovieStatusPublished {
+		canPublish, err := app.hasPermission(r, "movies:publish")
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+		if !canPublish {
+			app.notPermittedResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return movie, true
+}
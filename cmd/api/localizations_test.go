@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestMovieTitlesUpdatePermissions(t *testing.T) {
+	title := map[string]string{"title": "Vaiana"}
+
+	tests := []struct {
+		desc           string
+		publisher      bool
+		method         string
+		urlPath        string
+		body           any
+		expectedStatus int
+	}{
+		{"writer titles published", false, http.MethodPut, "/1/titles/fr", title, http.StatusForbidden},
+		{"writer titles draft", false, http.MethodPut, "/2/titles/fr", title, http.StatusOK},
+		{"publisher titles published", true, http.MethodPut, "/1/titles/fr", title, http.StatusOK},
+		{"writer deletes title of published", false, http.MethodDelete, "/1/titles/fr", nil, http.StatusForbidden},
+		{"writer deletes title of draft", false, http.MethodDelete, "/2/titles/fr", nil, http.StatusOK},
+		{"publisher deletes title of published", true, http.MethodDelete, "/1/titles/fr", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app, ts := newTestEnv(t, "Moana", "Untitled")
+			token := newTestEditor(t, app, tt.publisher)
+
+			for _, movieID := range []int64{1, 2} {
+				err := app.models.MovieTitles.Upsert(context.Background(), movieID, &data.MovieTitle{
+					Locale: "fr",
+					Title:  "Titre",
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res := ts.do(t, tt.method, "/api/v1/movies"+tt.urlPath, token, tt.body, nil)
+			if res.status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, res.status, res.body)
+			}
+		})
+	}
+}
+
+func TestLocalizedMovieETag(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana")
+
+	token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+	title := &data.MovieTitle{Locale: "fr", Title: "Vaiana"}
+	if err := app.models.MovieTitles.Upsert(context.Background(), 1, title); err != nil {
+		t.Fatal(err)
+	}
+
+	res := ts.do(t, http.MethodGet, "/api/v1/movies/1", token, nil, http.Header{"Accept-Language": {"en"}})
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+	etag := res.headers.Get("ETag")
+
+	french := http.Header{"Accept-Language": {"fr"}, "If-None-Match": {etag}}
+	res = ts.do(t, http.MethodGet, "/api/v1/movies/1", token, nil, french)
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+
+	var body movieResponse
+	res.decode(t, &body)
+
+	if body.Movie.Title != "Vaiana" {
+		t.Errorf("expected the French title, got %q", body.Movie.Title)
+	}
+	if got := strings.Join(res.headers.Values("Vary"), ", "); !strings.Contains(got, "Accept-Language") {
+		t.Errorf("expected the response to vary on Accept-Language, got %q", got)
+	}
+
+	french.Set("If-None-Match", res.headers.Get("ETag"))
+	res = ts.do(t, http.MethodGet, "/api/v1/movies/1", token, nil, french)
+	if res.status != http.StatusNotModified {
+		t.Errorf("expected status %d, got %d: %s", http.StatusNotModified, res.status, res.body)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"errors"
 	"expvar"
 	"flag"
 	"os"
@@ -17,6 +19,7 @@ import (
 	"github.com/zmwilliam/greenlight/internal/data"
 	"github.com/zmwilliam/greenlight/internal/jsonlog"
 	"github.com/zmwilliam/greenlight/internal/mailer"
+	"github.com/zmwilliam/greenlight/internal/storage"
 )
 
 const version = "0.0.1"
@@ -29,11 +32,14 @@ type config struct {
 		maxOpenConns int
 		maxIdleConns int
 		maxIdleTime  string
+		queryTimeout time.Duration
 	}
 	limiter struct {
-		rps     float64
-		burst   int
-		enabled bool
+		rps            float64
+		burst          int
+		anonymousRPS   float64
+		anonymousBurst int
+		enabled        bool
 	}
 	smtp struct {
 		host     string
@@ -45,14 +51,46 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	cursor struct {
+		secret string
+	}
+	search struct {
+		language string
+	}
+	conditional struct {
+		requireIfMatch bool
+	}
+	imports struct {
+		maxBytes     int64
+		syncMaxBytes int64
+	}
+	trash struct {
+		retention     time.Duration
+		purgeInterval time.Duration
+	}
+	storage struct {
+		dir     string
+		baseURL string
+	}
+	images struct {
+		maxBytes int64
+	}
+	stats struct {
+		cacheTTL time.Duration
+	}
+	public struct {
+		enabled bool
+	}
 }
 
 type application struct {
-	config config
-	logger *jsonlog.Logger
-	models data.Models
-	mailer mailer.Mailer
-	wg     sync.WaitGroup
+	config  config
+	logger  *jsonlog.Logger
+	models  data.Models
+	mailer  mailer.Mailer
+	storage storage.Store
+	stats   *statsCache
+	wg      sync.WaitGroup
 }
 
 func main() {
@@ -75,6 +113,12 @@ func main() {
 		"15m",
 		"PostgreSQL max connection idle time",
 	)
+	flag.DurationVar(
+		&cfg.db.queryTimeout,
+		"db-query-timeout",
+		3*time.Second,
+		"PostgreSQL query timeout",
+	)
 
 	flag.Float64Var(
 		&cfg.limiter.rps,
@@ -83,6 +127,18 @@ func main() {
 		"Rate limiter maximum requests per second",
 	)
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 2, "Rate limiter maximum requests per second")
+	flag.Float64Var(
+		&cfg.limiter.anonymousRPS,
+		"limiter-anonymous-rps",
+		1,
+		"Rate limiter maximum requests per second of anonymous public reads",
+	)
+	flag.IntVar(
+		&cfg.limiter.anonymousBurst,
+		"limiter-anonymous-burst",
+		2,
+		"Rate limiter maximum burst of anonymous public reads",
+	)
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
 	flag.StringVar(
@@ -117,10 +173,103 @@ func main() {
 		return nil
 	})
 
+	flag.StringVar(
+		&cfg.cursor.secret,
+		"cursor-secret",
+		getEnv("GREENLIGHT_CURSOR_SECRET", ""),
+		"Secret used to sign pagination cursors",
+	)
+
+	flag.StringVar(
+		&cfg.search.language,
+		"search-language",
+		getEnv("GREENLIGHT_SEARCH_LANGUAGE", "en"),
+		"Default language of movie search",
+	)
+
+	flag.BoolVar(
+		&cfg.conditional.requireIfMatch,
+		"require-if-match",
+		false,
+		"Require an If-Match header to update or delete movies",
+	)
+
+	flag.Int64Var(
+		&cfg.imports.maxBytes,
+		"import-max-bytes",
+		50*1024*1024,
+		"Maximum size of movie import uploads",
+	)
+	flag.Int64Var(
+		&cfg.imports.syncMaxBytes,
+		"import-sync-max-bytes",
+		1024*1024,
+		"Maximum size of movie imports processed during the request",
+	)
+
+	flag.DurationVar(
+		&cfg.trash.retention,
+		"trash-retention",
+		30*24*time.Hour,
+		"How long deleted movies are kept in the trash",
+	)
+	flag.DurationVar(
+		&cfg.trash.purgeInterval,
+		"trash-purge-interval",
+		time.Hour,
+		"How often the trash is purged",
+	)
+
+	flag.StringVar(
+		&cfg.storage.dir,
+		"storage-dir",
+		getEnv("GREENLIGHT_STORAGE_DIR", "./uploads"),
+		"Directory where uploaded images are stored",
+	)
+	flag.StringVar(
+		&cfg.storage.baseURL,
+		"storage-base-url",
+		getEnv("GREENLIGHT_STORAGE_BASE_URL", "/images"),
+		"Base URL of the uploaded images",
+	)
+	flag.Int64Var(
+		&cfg.images.maxBytes,
+		"image-max-bytes",
+		10*1024*1024,
+		"Maximum size of movie image uploads",
+	)
+
+	flag.DurationVar(
+		&cfg.stats.cacheTTL,
+		"stats-cache-ttl",
+		time.Minute,
+		"How long movie statistics are cached",
+	)
+
+	flag.BoolVar(
+		&cfg.public.enabled,
+		"public-enabled",
+		false,
+		"Let anonymous users browse the published movies",
+	)
+
 	flag.Parse()
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
+	if cfg.trash.purgeInterval <= 0 {
+		logger.PrintFatal(errors.New("trash purge interval must be positive"), nil)
+	}
+
+	if cfg.cursor.secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		cfg.cursor.secret = string(secret)
+		logger.PrintInfo("no cursor secret configured, cursors will not survive restarts", nil)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -128,6 +277,11 @@ func main() {
 	defer db.Close()
 	logger.PrintInfo("database connection pool established", nil)
 
+	store, err := storage.NewLocal(cfg.storage.dir, cfg.storage.baseURL)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	expvar.NewString("version").Set(version)
 	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
 	expvar.Publish("database", expvar.Func(func() any { return db.Stats() }))
@@ -136,7 +290,7 @@ func main() {
 	app := &application{
 		config: cfg,
 		logger: logger,
-		models: data.NewModels(db),
+		models: data.NewModels(db, cfg.db.queryTimeout),
 		mailer: mailer.New(
 			cfg.smtp.host,
 			cfg.smtp.port,
@@ -144,6 +298,8 @@ func main() {
 			cfg.smtp.password,
 			cfg.smtp.sender,
 		),
+		storage: store,
+		stats:   &statsCache{},
 	}
 
 	err = app.serve()
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"mime"
 	"net"
 	"net/http"
 	"strings"
@@ -46,9 +47,9 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 
 			mu.Lock()
 
-			for ip, client := range clients {
+			for key, client := range clients {
 				if time.Since(client.lastSeen) > 3*time.Minute {
-					delete(clients, ip)
+					delete(clients, key)
 				}
 			}
 
@@ -69,15 +70,23 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 			return
 		}
 
+		// The rate limiter runs before authenticate, so requests without
+		// credentials are taken as anonymous. They get their own, stricter,
+		// limiter when the catalog is public.
+		key, rps, burst := ip, limiter.rps, limiter.burst
+		if app.config.public.enabled && r.Header.Get("Authorization") == "" {
+			key, rps, burst = "anonymous "+ip, limiter.anonymousRPS, limiter.anonymousBurst
+		}
+
 		mu.Lock()
 
-		if _, found := clients[ip]; !found {
-			clients[ip] = &client{limiter: rate.NewLimiter(rate.Limit(limiter.rps), limiter.burst)}
+		if _, found := clients[key]; !found {
+			clients[key] = &client{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
 		}
 
-		clients[ip].lastSeen = time.Now()
+		clients[key].lastSeen = time.Now()
 
-		if !clients[ip].limiter.Allow() {
+		if !clients[key].limiter.Allow() {
 			mu.Unlock()
 			app.rateLimitExceededResponse(w, r)
 			return
@@ -89,6 +98,44 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 	})
 }
 
+// negotiateRuntimeFormat picks the format of the runtimes in the response,
+// from the runtime_format query param or else the runtime parameter of the
+// Accept header, as in "application/json; runtime=iso8601".
+func (app *application) negotiateRuntimeFormat(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		format := NewQueryParams(r).GetString("runtime_format", "")
+		if format == "" {
+			for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+				_, params, err := mime.ParseMediaType(mediaRange)
+				if err == nil && params["runtime"] != "" {
+					format = params["runtime"]
+					break
+				}
+			}
+		}
+
+		if format == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		v := validator.New()
+		v.Check(
+			validator.In(format, data.RuntimeFormats...),
+			"runtime_format",
+			"must be one of "+strings.Join(data.RuntimeFormats, ", "),
+		)
+		if !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetRuntimeFormat(r, format))
+	})
+}
+
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
@@ -115,7 +162,7 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		user, err := app.models.Users.GetForToken(r.Context(), data.ScopeAuthentication, token)
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -158,14 +205,48 @@ func (app *application) requireActivatedUser(next http.Handler) http.Handler {
 	return app.requireAuthenticatedUser(fn)
 }
 
+// hasPermission reports whether the user of the request has the permission,
+// reusing the permissions loaded by requirePermission when there are some.
+func (app *application) hasPermission(r *http.Request, code string) (bool, error) {
+	permissions, ok := app.contextGetPermissions(r)
+	if !ok {
+		user := app.contextGetUser(r)
+		if user.IsAnonymous() {
+			return false, nil
+		}
+
+		var err error
+		if permissions, err = app.models.Permissions.GetAllForUser(r.Context(), user.ID); err != nil {
+			return false, err
+		}
+	}
+
+	return permissions.Include(code), nil
+}
+
+// readsPublishedOnly reports whether the user of the request may only see
+// published movies, which is the case of everyone but writers and
+// publishers.
+func (app *application) readsPublishedOnly(r *http.Request) (bool, error) {
+	for _, code := range []string{"movies:write", "movies:publish"} {
+		ok, err := app.hasPermission(r, code)
+		if err != nil || ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
 func (app *application) requirePermission(code string) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := app.contextGetUser(r)
 
-			permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+			permissions, err := app.models.Permissions.GetAllForUser(r.Context(), user.ID)
 			if err != nil {
 				app.serverErrorResponse(w, r, err)
+				return
 			}
 
 			if !permissions.Include(code) {
@@ -173,12 +254,30 @@ func (app *application) requirePermission(code string) func(next http.Handler) h
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, app.contextSetPermissions(r, permissions))
 		})
 		return app.requireActivatedUser(fn)
 	}
 }
 
+// allowPublicRead lets anonymous users through when the catalog is public,
+// and requires the permission otherwise. Having no permissions, anonymous
+// users only see published movies.
+func (app *application) allowPublicRead(code string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withPermission := app.requirePermission(code)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if app.config.public.enabled && app.contextGetUser(r).IsAnonymous() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			withPermission.ServeHTTP(w, r)
+		})
+	}
+}
+
 func (app *application) enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Origin")
@@ -202,7 +301,7 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 						w.Header().
 							Set("Access-Control-Allow-Methods", strings.Join(allowMethods, ","))
 						w.Header().
-							Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+							Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match")
 
 						w.WriteHeader(http.StatusOK)
 						return
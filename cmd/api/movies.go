@@ -1,14 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/jsonpatch"
 	"github.com/zmwilliam/greenlight/internal/validator"
 )
 
@@ -50,14 +54,42 @@ func (q QueryParams) GetInt(key string, defaultValue int) (int, error) {
 	return i, nil
 }
 
+func (q QueryParams) GetBool(key string, defaultValue bool) (bool, error) {
+	s := q.params.Get(key)
+	if s == "" {
+		return defaultValue, nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return defaultValue, err
+	}
+
+	return b, nil
+}
+
+func (q QueryParams) Has(key string) bool {
+	return q.params.Has(key)
+}
+
 func NewQueryParams(r *http.Request) QueryParams {
 	return QueryParams{params: r.URL.Query()}
 }
 
+// readMovieProjection reads the fields and include query params of movie
+// responses.
+func readMovieProjection(qs QueryParams) data.Projection {
+	return data.Projection{
+		Fields:          qs.GetCSV("fields", []string{}),
+		FieldSafelist:   data.MovieFieldSafelist,
+		Include:         qs.GetCSV("include", []string{}),
+		IncludeSafelist: data.MovieIncludeSafelist,
+	}
+}
+
 func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieQuery
 		data.Filters
 	}
 
@@ -67,6 +99,21 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 
 	input.Title = qs.GetString("title", "")
 	input.Genres = qs.GetCSV("genres", []string{})
+	input.Tags = qs.GetCSV("tags", []string{})
+	for i, name := range input.Tags {
+		input.Tags[i] = data.NormalizeTag(name)
+	}
+	input.TagMatch = qs.GetString("tags_match", data.TagMatchAll)
+	input.Search = qs.GetString("search", "")
+	input.Language = qs.GetString("lang", app.config.search.language)
+	fuzzy, err := qs.GetBool("fuzzy", false)
+	if err != nil {
+		v.AddError("fuzzy", "invalid query param, must be boolean")
+	}
+	facets := qs.GetCSV("facets", []string{})
+	input.Locales = acceptedLocales(r)
+	input.Statuses = qs.GetCSV("status", []string{})
+	input.Projection = readMovieProjection(qs)
 	if input.Filters.Page, err = qs.GetInt("page", defaultPageNum); err != nil {
 		v.AddError("page", "invalid query param, must be integer")
 	}
@@ -77,33 +124,149 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	input.Filters.SortSafelist = []string{
 		"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime",
 	}
+	if input.Search != "" || fuzzy {
+		input.Filters.Sort = qs.GetString("sort", "relevance")
+		input.Filters.SortSafelist = append(input.Filters.SortSafelist, "relevance", "-relevance")
+	}
+
+	input.Filters.UseCursor = qs.Has("cursor")
+	input.Filters.Cursor = qs.GetString("cursor", "")
+	input.Filters.CursorSecret = []byte(app.config.cursor.secret)
+	if input.Filters.IncludeTotal, err = qs.GetBool("include_total", false); err != nil {
+		v.AddError("include_total", "invalid query param, must be boolean")
+	}
+	if input.Filters.UseCursor && qs.Has("page") {
+		v.AddError("page", "must not be used together with cursor")
+	}
+
+	publishedOnly, err := app.readsPublishedOnly(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if publishedOnly {
+		input.Statuses = []string{data.MovieStatusPublished}
+	}
+
+	data.ValidateMovieQuery(v, input.MovieQuery)
+	data.ValidateFacets(v, facets)
+	input.Projection.Validate(v)
 
 	if input.Filters.Validate(v); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	movies, meta, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, meta, err := app.models.Movies.GetAll(r.Context(), input.MovieQuery, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": meta}, nil)
+	// Only a query without any result falls back to fuzzy matching, not a
+	// page past the last one.
+	noResults := meta.TotalRecords == 0
+	if input.Filters.UseCursor {
+		noResults = len(movies) == 0 && input.Filters.Cursor == ""
+	}
+
+	if fuzzy && noResults && (input.Title != "" || input.Search != "") {
+		input.MovieQuery.Fuzzy = true
+
+		movies, meta, err = app.models.Movies.GetAll(r.Context(), input.MovieQuery, input.Filters)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		meta.Fuzzy = true
+	}
+
+	app.formatMovies(r, movies...)
+
+	projected, err := input.Projection.Apply(movies)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
+
+	env := envelope{"movies": projected, "metadata": meta}
+
+	if len(facets) > 0 {
+		env["facets"], err = app.models.Movies.Facets(r.Context(), input.MovieQuery, facets)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	etag, err := moviesETag(movies, meta, env["facets"], input.Projection)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+
+	if app.notModified(w, r, etag) {
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+
+	err = app.writeJSON(w, http.StatusOK, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+}
+
+func (app *application) autocompleteMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	text := strings.TrimSpace(qs.GetString("q", ""))
+	limit, err := qs.GetInt("limit", 10)
+	if err != nil {
+		v.AddError("limit", "invalid query param, must be integer")
+	}
+
+	v.Check(text != "", "q", "must be provided")
+	v.Check(len(text) <= 100, "q", "must not be longer than 100 bytes")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	publishedOnly, err := app.readsPublishedOnly(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	suggestions, err := app.models.Movies.Autocomplete(r.Context(), text, limit, publishedOnly)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"suggestions": suggestions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
 }
 
-func (app application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -114,17 +277,106 @@ func (app application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if err := app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil); err != nil {
+	app.showMovie(w, r, movie)
+}
+
+// showMovie sends the movie, localized to the request's languages and
+// restricted to the requested fields, unless the client already has the
+// current version. Unpublished movies are not found by readers. A single
+// movie is loaded with all its related data, so include only matters along
+// with fields, when it picks the related data kept.
+func (app *application) showMovie(w http.ResponseWriter, r *http.Request, movie *data.Movie) {
+	projection := readMovieProjection(NewQueryParams(r))
+
+	v := validator.New()
+	if projection.Validate(v); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if movie.Status != data.MovieStatusPublished {
+		publishedOnly, err := app.readsPublishedOnly(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+		if publishedOnly {
+			app.notFoundResponse(w, r)
+			return
+		}
+	}
+
+	app.formatMovies(r, movie)
+	locale := movie.Localize(acceptedLocales(r))
+	etag := localizedETag(projectedMovieETag(movie, projection), locale)
+
+	w.Header().Add("Vary", "Accept-Language")
+	if app.notModified(w, r, etag) {
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", etag)
+	if locale != "" {
+		headers.Set("Content-Language", locale)
+	}
+
+	projected, err := projection.Apply(movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": projected}, headers)
+	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
+// getVisibleMovie fetches the movie of the request's id parameter, which is
+// not found by readers unless it is published. It sends the error response
+// and returns false when it fails.
+func (app *application) getVisibleMovie(w http.ResponseWriter, r *http.Request) (*data.Movie, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	if movie.Status != data.MovieStatusPublished {
+		publishedOnly, err := app.readsPublishedOnly(r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return nil, false
+		}
+		if publishedOnly {
+			app.notFoundResponse(w, r)
+			return nil, false
+		}
+	}
+
+	return movie, true
+}
+
+func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Title   string       `json:"title"`
-		Year    int32        `json:"year"`
-		Runtime data.Runtime `json:"runtime"`
-		Genres  []string     `json:"genres"`
+		Title       string            `json:"title"`
+		Year        int32             `json:"year"`
+		Runtime     data.Runtime      `json:"runtime"`
+		Genres      []string          `json:"genres"`
+		ExternalIDs []data.ExternalID `json:"external_ids"`
+		Status      string            `json:"status"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -134,33 +386,72 @@ func (app application) createMovieHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	movie := &data.Movie{
-		Title:   input.Title,
-		Year:    input.Year,
-		Runtime: input.Runtime,
-		Genres:  input.Genres,
+		Title:       input.Title,
+		Year:        input.Year,
+		Runtime:     input.Runtime,
+		Genres:      input.Genres,
+		ExternalIDs: input.ExternalIDs,
 	}
 
 	v := validator.New()
-	if data.ValidateMovie(v, movie); !v.Valid() {
+
+	force, err := NewQueryParams(r).GetBool("force", false)
+	if err != nil {
+		v.AddError("force", "invalid query param, must be boolean")
+	}
+
+	if movie.Status, err = app.newMovieStatus(v, r, input.Status); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	data.ValidateMovie(v, movie)
+	for i, externalID := range movie.ExternalIDs {
+		data.ValidateExternalID(v, fmt.Sprintf("external_ids[%d]", i), externalID)
+	}
+
+	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.Movies.Insert(movie)
+	// Movies with the same title and year are most likely duplicates, but
+	// remakes happen, so the check can be skipped.
+	if !force {
+		existing, err := app.models.Movies.FindDuplicate(r.Context(), movie.Title, movie.Year)
+		switch {
+		case err == nil:
+			app.duplicateMovieResponse(w, r, "a movie with the same title and year already exists", existing.ID)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.models.Movies.Insert(r.Context(), movie, app.contextGetUser(r).ID)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrDuplicateExternalID):
+			app.duplicateExternalIDResponse(w, r, movie.ExternalIDs)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
+	app.formatMovies(r, movie)
+
 	headers := make(http.Header)
 	headers.Set("Location", fmt.Sprintf("/api/v1/movies/%d", movie.ID))
+	headers.Set("ETag", movieETag(movie))
 	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Title   string       `json:"title"`
 		Year    int32        `json:"year"`
@@ -168,17 +459,33 @@ func (app application) updateMovieHandler(w http.ResponseWriter, r *http.Request
 		Genres  []string     `json:"genres"`
 	}
 
-	updateMovie := func(movie *data.Movie) {
+	updateMovie := func(movie *data.Movie) error {
 		movie.Title = input.Title
 		movie.Year = input.Year
 		movie.Runtime = input.Runtime
 		movie.Genres = input.Genres
+		return nil
 	}
 
 	app.readValidateAndUpdateMovie(w, r, &input, updateMovie)
 }
 
-func (app application) patchMovieHandler(w http.ResponseWriter, r *http.Request) {
+// patchMovieHandler accepts JSON Merge Patch and JSON Patch documents, or a
+// plain JSON object of the fields to change.
+func (app *application) patchMovieHandler(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if mediaType == jsonpatch.MergePatchMediaType || mediaType == jsonpatch.JSONPatchMediaType {
+		var patch json.RawMessage
+
+		applyPatch := func(movie *data.Movie) error {
+			return applyMoviePatch(movie, mediaType, patch)
+		}
+
+		app.readValidateAndUpdateMovie(w, r, &patch, applyPatch)
+		return
+	}
+
 	var input struct {
 		Title   *string       `json:"title"`
 		Year    *int32        `json:"year"`
@@ -186,7 +493,7 @@ func (app application) patchMovieHandler(w http.ResponseWriter, r *http.Request)
 		Genres  []string      `json:"genres"`
 	}
 
-	patchUpdate := func(movie *data.Movie) {
+	patchUpdate := func(movie *data.Movie) error {
 		if input.Title != nil {
 			movie.Title = *input.Title
 		}
@@ -199,16 +506,17 @@ func (app application) patchMovieHandler(w http.ResponseWriter, r *http.Request)
 		if input.Genres != nil {
 			movie.Genres = input.Genres
 		}
+		return nil
 	}
 
 	app.readValidateAndUpdateMovie(w, r, &input, patchUpdate)
 }
 
-func (app application) readValidateAndUpdateMovie(
+func (app *application) readValidateAndUpdateMovie(
 	w http.ResponseWriter,
 	r *http.Request,
 	readInto any,
-	update_attrs func(m *data.Movie),
+	update_attrs func(m *data.Movie) error,
 ) {
 	id, err := app.readIDParam(r)
 	if err != nil {
@@ -216,7 +524,7 @@ func (app application) readValidateAndUpdateMovie(
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -227,13 +535,27 @@ func (app application) readValidateAndUpdateMovie(
 		return
 	}
 
+	app.formatMovies(r, movie)
+	if !app.preconditionMet(w, r, movieETag(movie)) {
+		return
+	}
+
 	err = app.readJSON(w, r, &readInto)
 	if err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
 
-	update_attrs(movie)
+	err = update_attrs(movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, jsonpatch.ErrTestFailed):
+			app.patchConflictResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
 
 	v := validator.New()
 	if data.ValidateMovie(v, movie); !v.Valid() {
@@ -241,9 +563,11 @@ func (app application) readValidateAndUpdateMovie(
 		return
 	}
 
-	err = app.models.Movies.Update(movie)
+	change, err := app.saveMovieEdit(r, app.models.Movies, movie)
 	if err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
 		case errors.Is(err, data.ErrEditConflict):
 			app.editConflictResponse(w, r)
 		default:
@@ -252,21 +576,125 @@ func (app application) readValidateAndUpdateMovie(
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, nil)
+	if change != nil {
+		app.movieChangeAcceptedResponse(w, r, change)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"movie": movie}, headers)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
+// applyMoviePatch applies a patch document of the given media type to the
+// JSON representation of the movie. The id and version are part of the
+// document, so they can be tested, but must not be changed.
+func applyMoviePatch(movie *data.Movie, mediaType string, patch []byte) error {
+	// Images, alternate titles, releases, external ids and tags are managed
+	// through their own endpoints, so they are left out of the document to patch.
+	target := *movie
+	target.Images = nil
+	target.Titles = nil
+	target.Releases = nil
+	target.ExternalIDs = nil
+	target.Tags = nil
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+
+	switch mediaType {
+	case jsonpatch.MergePatchMediaType:
+		doc, err = jsonpatch.MergePatch(doc, patch)
+	case jsonpatch.JSONPatchMediaType:
+		doc, err = jsonpatch.Apply(doc, patch)
+	}
+	if err != nil {
+		return err
+	}
+
+	var patched struct {
+		ID      int64        `json:"id"`
+		Title   string       `json:"title"`
+		Year    int32        `json:"year"`
+		Runtime data.Runtime `json:"runtime"`
+		Genres  []string     `json:"genres"`
+		Version int32        `json:"version"`
+		Status  string       `json:"status"`
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(&patched); err != nil {
+		return fmt.Errorf("%w: %s", jsonpatch.ErrInvalidPatch, err)
+	}
+
+	if patched.ID != movie.ID || patched.Version != movie.Version || patched.Status != movie.Status {
+		return fmt.Errorf("%w: id, version and status must not be changed", jsonpatch.ErrInvalidPatch)
+	}
+
+	movie.Title = patched.Title
+	movie.Year = patched.Year
+	movie.Runtime = patched.Runtime
+	movie.Genres = patched.Genres
+
+	return nil
+}
+
+func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := app.readIDParam(r)
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
-	if err = app.models.Movies.Delete(id); err != nil {
+	canPublish, err := app.hasPermission(r, "movies:publish")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Published movies can only be taken down by publishers. Once loaded,
+	// the movie is only deleted if it did not change in the meantime.
+	var version int32
+
+	if !canPublish || r.Header.Get("If-Match") != "" || app.config.conditional.requireIfMatch {
+		movie, err := app.models.Movies.Get(r.Context(), id)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if !canPublish && movie.Status == data.MovieStatusPublished {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		app.formatMovies(r, movie)
+		if !app.preconditionMet(w, r, movieETag(movie)) {
+			return
+		}
+
+		version = movie.Version
+	}
+
+	if err = app.models.Movies.Delete(r.Context(), id, version); err != nil {
 		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
 		case errors.Is(err, data.ErrRecordNotFound):
 			app.notFoundResponse(w, r)
 		default:
@@ -278,7 +706,7 @@ func (app application) deleteMovieHandler(w http.ResponseWriter, r *http.Request
 	err = app.writeJSON(
 		w,
 		http.StatusCreated,
-		envelope{"message": "movie succesfully deleted"},
+		envelope{"message": "movie succesfully moved to trash"},
 		nil,
 	)
 	if err != nil {
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/jsonpatch"
+)
+
+type movieResponse struct {
+	Movie struct {
+		ID      int64    `json:"id"`
+		Title   string   `json:"title"`
+		Year    int32    `json:"year"`
+		Runtime string   `json:"runtime"`
+		Genres  []string `json:"genres"`
+		Version int32    `json:"version"`
+		Status  string   `json:"status"`
+	} `json:"movie"`
+}
+
+func TestMovieHandlers(t *testing.T) {
+	app, ts := newTestEnv(t)
+
+	token := newTestEditor(t, app, true)
+
+	res := ts.do(t, http.MethodPost, "/api/v1/movies", token, map[string]any{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": "107 mins",
+		"genres":  []string{"animation", "adventure"},
+	}, nil)
+	if res.status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+	}
+
+	var created movieResponse
+	res.decode(t, &created)
+
+	location := fmt.Sprintf("/api/v1/movies/%d", created.Movie.ID)
+	if got := res.headers.Get("Location"); got != location {
+		t.Errorf("expected location %q, got %q", location, got)
+	}
+	if created.Movie.Version != 1 || created.Movie.Status != data.MovieStatusPublished {
+		t.Errorf("unexpected movie %+v", created.Movie)
+	}
+
+	res = ts.do(t, http.MethodGet, location, token, nil, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+
+	var shown movieResponse
+	res.decode(t, &shown)
+
+	if diff := cmp.Diff(created, shown); diff != "" {
+		t.Errorf("movie does not match (-want, +got):\n%s", diff)
+	}
+
+	etag := res.headers.Get("ETag")
+	update := map[string]any{
+		"title":   "Moana",
+		"year":    2016,
+		"runtime": "107 mins",
+		"genres":  []string{"animation", "adventure", "musical"},
+	}
+
+	res = ts.do(t, http.MethodPut, location, token, update, http.Header{"If-Match": {etag}})
+	if res.status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+	}
+
+	var updated movieResponse
+	res.decode(t, &updated)
+
+	if updated.Movie.Version != 2 || len(updated.Movie.Genres) != 3 {
+		t.Errorf("unexpected movie %+v", updated.Movie)
+	}
+
+	// The movie changed since the ETag was sent.
+	res = ts.do(t, http.MethodPut, location, token, update, http.Header{"If-Match": {etag}})
+	if res.status != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got %d: %s", http.StatusPreconditionFailed, res.status, res.body)
+	}
+
+	res = ts.do(t, http.MethodDelete, location, token, nil, nil)
+	if res.status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+	}
+
+	res = ts.do(t, http.MethodGet, location, token, nil, nil)
+	if res.status != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d: %s", http.StatusNotFound, res.status, res.body)
+	}
+}
+
+func TestListMoviesHandler(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana", "Black Panther", "Deadpool", "The Breakfast Club", "Untitled")
+
+	token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+	tests := []struct {
+		desc           string
+		query          string
+		expectedTitles []string
+		expectedMeta   data.Metadata
+	}{
+		{
+			desc:           "published movies by id",
+			query:          "",
+			expectedTitles: []string{"Moana", "Black Panther", "Deadpool", "The Breakfast Club"},
+			expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 4},
+		},
+		{
+			desc:           "filtered by genre",
+			query:          "?genres=action",
+			expectedTitles: []string{"Black Panther", "Deadpool"},
+			expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 2},
+		},
+		{
+			desc:           "sorted by year descending, then id",
+			query:          "?sort=-year",
+			expectedTitles: []string{"Black Panther", "Moana", "Deadpool", "The Breakfast Club"},
+			expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 4},
+		},
+		{
+			desc:           "second page",
+			query:          "?sort=title&page=2&page_size=3",
+			expectedTitles: []string{"The Breakfast Club"},
+			expectedMeta:   data.Metadata{CurrentPage: 2, PageSize: 3, FirstPage: 1, LastPage: 2, TotalRecords: 4},
+		},
+		{
+			desc:           "page past the end",
+			query:          "?page=3&page_size=3",
+			expectedTitles: []string{},
+			expectedMeta:   data.Metadata{CurrentPage: 3, PageSize: 3, FirstPage: 1, LastPage: 2, TotalRecords: 4},
+		},
+		{
+			desc:           "fuzzy fallback without exact matches",
+			query:          "?title=oana&fuzzy=true",
+			expectedTitles: []string{"Moana"},
+			expectedMeta: data.Metadata{
+				CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 1, Fuzzy: true,
+			},
+		},
+		{
+			desc:           "no fuzzy fallback past the last page",
+			query:          "?title=moana&fuzzy=true&page=2",
+			expectedTitles: []string{},
+			expectedMeta:   data.Metadata{CurrentPage: 2, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/api/v1/movies"+tt.query, token, nil, nil)
+			if res.status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+			}
+
+			var body struct {
+				Movies []struct {
+					Title string `json:"title"`
+				} `json:"movies"`
+				Metadata data.Metadata `json:"metadata"`
+			}
+			res.decode(t, &body)
+
+			titles := []string{}
+			for _, movie := range body.Movies {
+				titles = append(titles, movie.Title)
+			}
+
+			if diff := cmp.Diff(tt.expectedTitles, titles); diff != "" {
+				t.Errorf("titles does not match (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.expectedMeta, body.Metadata); diff != "" {
+				t.Errorf("metadata does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListMoviesFacets(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana", "Black Panther", "Deadpool", "The Breakfast Club", "Untitled")
+
+	token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+	tests := []struct {
+		desc           string
+		query          string
+		expectedFacets data.Facets
+	}{
+		{
+			desc:  "published movies",
+			query: "?facets=genres,decade",
+			expectedFacets: data.Facets{
+				"genres": {
+					{Value: "action", Count: 2},
+					{Value: "animation", Count: 1},
+					{Value: "comedy", Count: 1},
+					{Value: "drama", Count: 1},
+				},
+				"decade": {{Value: "1980s", Count: 1}, {Value: "2010s", Count: 3}},
+			},
+		},
+		{
+			desc:  "filtered by genre",
+			query: "?genres=action&facets=genres,decade,runtime",
+			expectedFacets: data.Facets{
+				"genres":  {{Value: "action", Count: 2}, {Value: "comedy", Count: 1}},
+				"decade":  {{Value: "2010s", Count: 2}},
+				"runtime": {{Value: "90-119", Count: 1}, {Value: "120-149", Count: 1}},
+			},
+		},
+		{
+			desc:  "filtered by title and a page past the end",
+			query: "?title=moana&page=2&facets=runtime",
+			expectedFacets: data.Facets{
+				"runtime": {{Value: "90-119", Count: 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, "/api/v1/movies"+tt.query, token, nil, nil)
+			if res.status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+			}
+
+			var body struct {
+				Facets data.Facets `json:"facets"`
+			}
+			res.decode(t, &body)
+
+			if diff := cmp.Diff(tt.expectedFacets, body.Facets); diff != "" {
+				t.Errorf("facets does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+
+	res := ts.do(t, http.MethodGet, "/api/v1/movies?facets=studio", token, nil, nil)
+	if res.status != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d for an unknown facet, got %d: %s", http.StatusUnprocessableEntity, res.status, res.body)
+	}
+}
+
+func TestPublicMovieReads(t *testing.T) {
+	tests := []struct {
+		desc           string
+		public         bool
+		method         string
+		urlPath        string
+		expectedStatus int
+	}{
+		{"private list", false, http.MethodGet, "/api/v1/movies", http.StatusUnauthorized},
+		{"private movie", false, http.MethodGet, "/api/v1/movies/1", http.StatusUnauthorized},
+		{"public list", true, http.MethodGet, "/api/v1/movies", http.StatusOK},
+		{"public movie", true, http.MethodGet, "/api/v1/movies/1", http.StatusOK},
+		{"public draft", true, http.MethodGet, "/api/v1/movies/2", http.StatusNotFound},
+		{"public write", true, http.MethodDelete, "/api/v1/movies/1", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app, ts := newTestEnv(t, "Moana", "Untitled")
+			app.config.public.enabled = tt.public
+
+			res := ts.do(t, tt.method, tt.urlPath, "", nil, nil)
+			if res.status != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, res.status, res.body)
+			}
+
+			if tt.urlPath == "/api/v1/movies" && res.status == http.StatusOK {
+				var body struct {
+					Movies []struct {
+						Title string `json:"title"`
+					} `json:"movies"`
+				}
+				res.decode(t, &body)
+
+				if len(body.Movies) != 1 || body.Movies[0].Title != "Moana" {
+					t.Errorf("expected only the published movie, got %+v", body.Movies)
+				}
+			}
+		})
+	}
+}
+
+func TestMovieRuntimeFormat(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana")
+
+	token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+	res := ts.do(t, http.MethodGet, "/api/v1/movies/1", token, nil, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+	etag := res.headers.Get("ETag")
+
+	tests := []struct {
+		desc     string
+		urlPath  string
+		headers  http.Header
+		expected string
+	}{
+		{desc: "query param", urlPath: "/api/v1/movies/1?runtime_format=iso8601", expected: "PT1H47M"},
+		{
+			desc:     "accept header",
+			urlPath:  "/api/v1/movies/1",
+			headers:  http.Header{"Accept": {"application/json; runtime=human"}},
+			expected: "1h 47m",
+		},
+		{desc: "projection", urlPath: "/api/v1/movies/1?fields=runtime&runtime_format=human", expected: "1h 47m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			headers := http.Header{"If-None-Match": {etag}}
+			for key, values := range tt.headers {
+				headers[key] = values
+			}
+
+			// The tag of the default format must not match another format.
+			res := ts.do(t, http.MethodGet, tt.urlPath, token, nil, headers)
+			if res.status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+			}
+
+			var body movieResponse
+			res.decode(t, &body)
+
+			if body.Movie.Runtime != tt.expected {
+				t.Errorf("expected runtime %q, got %q", tt.expected, body.Movie.Runtime)
+			}
+		})
+	}
+
+	res = ts.do(t, http.MethodGet, "/api/v1/movies/stats?runtime_format=iso8601", token, nil, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+
+	var stats struct {
+		Stats struct {
+			Runtime map[string]string `json:"runtime"`
+		} `json:"stats"`
+	}
+	res.decode(t, &stats)
+
+	if got := stats.Stats.Runtime["max"]; got != "PT1H47M" {
+		t.Errorf("expected max runtime %q, got %q", "PT1H47M", got)
+	}
+}
+
+func TestMovieProjectionETag(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana")
+
+	token := newTestUser(t, app, "reader@example.com", "movies:read")
+
+	for _, urlPath := range []string{"/api/v1/movies/1", "/api/v1/movies"} {
+		t.Run(urlPath, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, urlPath, token, nil, nil)
+			if res.status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+			}
+			etag := res.headers.Get("ETag")
+
+			res = ts.do(t, http.MethodGet, urlPath+"?fields=id", token, nil, http.Header{"If-None-Match": {etag}})
+			if res.status != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+			}
+
+			projectedETag := res.headers.Get("ETag")
+			if projectedETag == etag {
+				t.Errorf("expected the projection to have its own etag, got %s", etag)
+			}
+
+			res = ts.do(t, http.MethodGet, urlPath+"?fields=id", token, nil, http.Header{"If-None-Match": {projectedETag}})
+			if res.status != http.StatusNotModified {
+				t.Errorf("expected status %d, got %d: %s", http.StatusNotModified, res.status, res.body)
+			}
+		})
+	}
+}
+
+func TestPatchTaggedMovie(t *testing.T) {
+	tests := []struct {
+		desc        string
+		contentType string
+		patch       any
+	}{
+		{desc: "merge patch", contentType: jsonpatch.MergePatchMediaType, patch: map[string]any{"year": 2018}},
+		{
+			desc:        "json patch",
+			contentType: jsonpatch.JSONPatchMediaType,
+			patch:       []map[string]any{{"op": "replace", "path": "/year", "value": 2018}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app, ts := newTestEnv(t, "Moana")
+
+			token := newTestEditor(t, app, true)
+
+			if err := app.models.Tags.AddToMovie(context.Background(), 1, []string{"pixar"}, 0, true); err != nil {
+				t.Fatal(err)
+			}
+
+			res := ts.do(t, http.MethodPatch, "/api/v1/movies/1", token, tt.patch, http.Header{
+				"Content-Type": {tt.contentType},
+			})
+			if res.status != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+			}
+
+			var body movieResponse
+			res.decode(t, &body)
+
+			if body.Movie.Year != 2018 {
+				t.Errorf("expected year 2018, got %d", body.Movie.Year)
+			}
+		})
+	}
+}
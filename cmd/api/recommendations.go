@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// readRankedMoviesQuery reads the query, projection and filters of a listing
+// of movies ranked by their relevance, which is always the sort order.
+// Readers only get published movies.
+func (app *application) readRankedMoviesQuery(
+	r *http.Request,
+	v *validator.Validator,
+) (data.MovieQuery, data.Filters, error) {
+	var (
+		q       data.MovieQuery
+		filters data.Filters
+		err     error
+	)
+
+	qs := NewQueryParams(r)
+
+	q.Locales = acceptedLocales(r)
+	q.Statuses = qs.GetCSV("status", []string{})
+	q.Projection = readMovieProjection(qs)
+	if filters.Page, err = qs.GetInt("page", defaultPageNum); err != nil {
+		v.AddError("page", "invalid query param, must be integer")
+	}
+	if filters.PageSize, err = qs.GetInt("page_size", defaultPageSize); err != nil {
+		v.AddError("page_size", "invalid query param, must be integer")
+	}
+	filters.Sort = "relevance"
+	filters.SortSafelist = []string{"relevance"}
+
+	publishedOnly, err := app.readsPublishedOnly(r)
+	if err != nil {
+		return q, filters, err
+	}
+	if publishedOnly {
+		q.Statuses = []string{data.MovieStatusPublished}
+	}
+
+	data.ValidateMovieQuery(v, q)
+	q.Projection.Validate(v)
+	filters.Validate(v)
+
+	return q, filters, nil
+}
+
+func (app *application) listSimilarMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+
+	q, filters, err := app.readRankedMoviesQuery(r, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, meta, err := app.models.Movies.GetSimilar(r.Context(), movie, q, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	app.formatMovies(r, movies...)
+
+	projected, err := q.Projection.Apply(movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": projected, "metadata": meta}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+
+	q, filters, err := app.readRankedMoviesQuery(r, v)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	movies, meta, err := app.models.Movies.GetRecommendations(r.Context(), user.ID, q, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Language")
+	app.formatMovies(r, movies...)
+
+	projected, err := q.Projection.Apply(movies)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": projected, "metadata": meta}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	rating, err := app.models.MovieRatings.Get(r.Context(), app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if err = app.writeJSON(w, http.StatusOK, envelope{"rating": rating}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) putMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Rating int `json:"rating"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	rating := &data.MovieRating{MovieID: movie.ID, Rating: input.Rating}
+
+	v := validator.New()
+	if data.ValidateMovieRating(v, rating); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if err := app.models.MovieRatings.Upsert(r.Context(), app.contextGetUser(r).ID, rating); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err := app.writeJSON(w, http.StatusOK, envelope{"rating": rating}, nil); err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieRatingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.MovieRatings.Delete(r.Context(), app.contextGetUser(r).ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "rating successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
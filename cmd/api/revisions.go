@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func (app *application) listMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.models.MovieRevisions.GetAllForMovie(r.Context(), movie.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, revision := range revisions {
+		revision.RuntimeFormat = app.contextGetRuntimeFormat(r)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revisions": revisions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showMovieRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	revision, err := app.models.MovieRevisions.Get(r.Context(), movie.ID, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	revision.RuntimeFormat = app.contextGetRuntimeFormat(r)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"revision": revision}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) diffMovieRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getVisibleMovie(w, r)
+	if !ok {
+		return
+	}
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	from, err := qs.GetInt("from", 0)
+	if err != nil {
+		v.AddError("from", "invalid query param, must be integer")
+	}
+	to, err := qs.GetInt("to", 0)
+	if err != nil {
+		v.AddError("to", "invalid query param, must be integer")
+	}
+
+	v.Check(from > 0, "from", "must be greater than zero")
+	v.Check(to > 0, "to", "must be greater than zero")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	var revisions [2]*data.MovieRevision
+	for i, version := range []int{from, to} {
+		revisions[i], err = app.models.MovieRevisions.Get(r.Context(), movie.ID, int32(version))
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+		revisions[i].RuntimeFormat = app.contextGetRuntimeFormat(r)
+	}
+
+	env := envelope{
+		"from":    from,
+		"to":      to,
+		"changes": data.DiffMovieRevisions(revisions[0], revisions[1]),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// restoreMovieRevisionHandler saves the snapshot of an old revision as the
+// latest version of the movie.
+func (app *application) restoreMovieRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	version, err := app.readVersionParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	revision, err := app.models.MovieRevisions.Get(r.Context(), id, version)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.formatMovies(r, movie)
+	if !app.preconditionMet(w, r, movieETag(movie)) {
+		return
+	}
+
+	revision.Apply(movie)
+
+	change, err := app.saveMovieEdit(r, app.models.Movies, movie)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict) && r.Header.Get("If-Match") != "":
+			app.preconditionFailedResponse(w, r)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if change != nil {
+		app.movieChangeAcceptedResponse(w, r, change)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("ETag", movieETag(movie))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
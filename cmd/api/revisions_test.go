@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMovieRevisionsVisibility(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana", "Untitled")
+
+	reader := newTestUser(t, app, "reader@example.com", "movies:read")
+	writer := newTestEditor(t, app, false)
+
+	tests := []struct {
+		desc           string
+		token          string
+		urlPath        string
+		expectedStatus int
+	}{
+		{"reader lists published", reader, "/api/v1/movies/1/revisions", http.StatusOK},
+		{"reader lists draft", reader, "/api/v1/movies/2/revisions", http.StatusNotFound},
+		{"reader shows draft", reader, "/api/v1/movies/2/revisions/1", http.StatusNotFound},
+		{"reader diffs draft", reader, "/api/v1/movies/2/revisions/diff?from=1&to=1", http.StatusNotFound},
+		{"writer lists draft", writer, "/api/v1/movies/2/revisions", http.StatusOK},
+		{"writer shows draft", writer, "/api/v1/movies/2/revisions/1", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			res := ts.do(t, http.MethodGet, tt.urlPath, tt.token, nil, nil)
+			if res.status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, res.status, res.body)
+			}
+		})
+	}
+}
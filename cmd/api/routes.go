@@ -3,8 +3,12 @@ package main
 import (
 	"expvar"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/storage"
 )
 
 func (app *application) routes() http.Handler {
@@ -14,6 +18,7 @@ func (app *application) routes() http.Handler {
 	r.Use(app.enableCORS)
 	r.Use(app.rateLimit)
 	r.Use(app.authenticate)
+	r.Use(app.negotiateRuntimeFormat)
 
 	r.NotFound(app.notFoundResponse)
 	r.MethodNotAllowed(app.methodNotAllowedResponse)
@@ -21,21 +26,95 @@ func (app *application) routes() http.Handler {
 	r.Route("/api/v1", func(r chi.Router) {
 		r.Get("/healthcheck", app.healthcheckHandler)
 
+		// Every route requires a permission, and so an activated user, except
+		// for the public reads of the catalog.
 		r.Route("/movies", func(r chi.Router) {
-			r.Use(app.requireActivatedUser)
-
-			r.With(app.requirePermission("movies:read")).Get("/", app.listMoviesHandler)
+			r.With(app.allowPublicRead("movies:read")).Get("/", app.listMoviesHandler)
 			r.With(app.requirePermission("movies:write")).Post("/", app.createMovieHandler)
+			r.With(app.allowPublicRead("movies:read")).
+				Get("/autocomplete", app.autocompleteMoviesHandler)
+			r.With(app.requirePermission("movies:write")).Post("/batch", app.batchMoviesHandler)
+			r.With(app.requirePermission("movies:read")).Get("/export", app.exportMoviesHandler)
+			r.With(app.allowPublicRead("movies:read")).Get("/stats", app.movieStatsHandler)
+			r.With(app.allowPublicRead("movies:read")).
+				Get("/by-external/{source}/{id}", app.showMovieByExternalIDHandler)
+
+			r.Route("/changes", func(r chi.Router) {
+				r.With(app.requirePermission("movies:publish")).Get("/", app.listMovieChangesHandler)
+				r.With(app.requirePermission("movies:write")).Get("/{id}", app.showMovieChangeHandler)
+				r.With(app.requirePermission("movies:publish")).
+					Post("/{id}/approve", app.approveMovieChangeHandler)
+				r.With(app.requirePermission("movies:publish")).
+					Post("/{id}/reject", app.rejectMovieChangeHandler)
+			})
+			r.With(app.requirePermission("movies:write")).Get("/trash", app.listTrashedMoviesHandler)
+			r.With(app.requirePermission("movies:write")).Post("/import", app.importMoviesHandler)
+			r.With(app.requirePermission("movies:write")).Get("/import/{id}", app.showImportJobHandler)
 
-			r.With(app.requirePermission("movies:read")).Get("/{id}", app.showMovieHandler)
+			r.With(app.allowPublicRead("movies:read")).Get("/{id}", app.showMovieHandler)
 			r.With(app.requirePermission("movies:write")).Put("/{id}", app.updateMovieHandler)
 			r.With(app.requirePermission("movies:write")).Patch("/{id}", app.patchMovieHandler)
 			r.With(app.requirePermission("movies:write")).Delete("/{id}", app.deleteMovieHandler)
+			r.With(app.requirePermission("movies:write")).
+				Post("/{id}/restore", app.restoreMovieHandler)
+			r.With(app.requirePermission("movies:write")).
+				Post("/{id}/submit", app.submitMovieHandler)
+			r.With(app.requirePermission("movies:publish")).
+				Put("/{id}/status", app.updateMovieStatusHandler)
+
+			r.With(app.requirePermission("movies:write")).
+				Post("/{id}/images", app.uploadMovieImageHandler)
+			r.With(app.requirePermission("movies:write")).
+				Delete("/{id}/images/{kind}", app.deleteMovieImageHandler)
+
+			r.With(app.requirePermission("movies:write")).
+				Put("/{id}/titles/{locale}", app.putMovieTitleHandler)
+			r.With(app.requirePermission("movies:write")).
+				Delete("/{id}/titles/{locale}", app.deleteMovieTitleHandler)
+			r.With(app.requirePermission("movies:write")).
+				Put("/{id}/releases/{country}", app.putMovieReleaseHandler)
+			r.With(app.requirePermission("movies:write")).
+				Delete("/{id}/releases/{country}", app.deleteMovieReleaseHandler)
+
+			r.With(app.requirePermission("movies:write")).
+				Post("/{id}/external-ids", app.addMovieExternalIDHandler)
+			r.With(app.requirePermission("movies:write")).
+				Delete("/{id}/external-ids/{source}/{externalID}", app.deleteMovieExternalIDHandler)
+			r.With(app.requirePermission("movies:write")).Post("/{id}/tags", app.addMovieTagsHandler)
+			r.With(app.requirePermission("movies:write")).
+				Delete("/{id}/tags/{tag}", app.deleteMovieTagHandler)
+			r.With(app.requirePermission("movies:admin")).Post("/{id}/merge", app.mergeMoviesHandler)
+
+			r.With(app.allowPublicRead("movies:read")).Get("/{id}/similar", app.listSimilarMoviesHandler)
+			r.With(app.requirePermission("movies:read")).Get("/{id}/rating", app.showMovieRatingHandler)
+			r.With(app.requirePermission("movies:read")).Put("/{id}/rating", app.putMovieRatingHandler)
+			r.With(app.requirePermission("movies:read")).
+				Delete("/{id}/rating", app.deleteMovieRatingHandler)
+
+			r.Route("/{id}/revisions", func(r chi.Router) {
+				r.With(app.requirePermission("movies:read")).Get("/", app.listMovieRevisionsHandler)
+				r.With(app.requirePermission("movies:read")).
+					Get("/diff", app.diffMovieRevisionsHandler)
+				r.With(app.requirePermission("movies:read")).
+					Get("/{version}", app.showMovieRevisionHandler)
+				r.With(app.requirePermission("movies:write")).
+					Post("/{version}/restore", app.restoreMovieRevisionHandler)
+			})
+		})
+
+		r.Route("/tags", func(r chi.Router) {
+			r.With(app.requirePermission("movies:publish")).Get("/", app.listTagsHandler)
+			r.With(app.allowPublicRead("movies:read")).
+				Get("/autocomplete", app.autocompleteTagsHandler)
+			r.With(app.requirePermission("movies:publish")).
+				Put("/{id}/status", app.updateTagStatusHandler)
 		})
 
 		r.Route("/users", func(r chi.Router) {
 			r.Post("/", app.registerUserHandler)
 			r.Put("/activated", app.activateUserHandler)
+			r.With(app.requirePermission("movies:read")).
+				Get("/me/recommendations", app.listRecommendationsHandler)
 		})
 
 		r.Post("/tokens/authentication", app.createAuthTokenHandler)
@@ -43,5 +122,14 @@ func (app *application) routes() http.Handler {
 
 	r.Handle("/debug/vars", expvar.Handler())
 
+	// Images kept on the local filesystem are served by the API itself, at
+	// the path of the storage base URL.
+	if local, ok := app.storage.(*storage.Local); ok {
+		u, err := url.Parse(app.config.storage.baseURL)
+		if path := strings.TrimSuffix(u.Path, "/"); err == nil && path != "" {
+			r.Handle(path+"/*", http.StripPrefix(path, local))
+		}
+	}
+
 	return r
 }
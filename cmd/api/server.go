@@ -24,6 +24,9 @@ func (app *application) serve() error {
 
 	shutdownError := make(chan error)
 
+	stopPurge := make(chan struct{})
+	app.purgeTrash(stopPurge)
+
 	go func() {
 		quit := make(chan os.Signal, 1)
 
@@ -43,6 +46,7 @@ func (app *application) serve() error {
 
 		app.logger.PrintInfo("completing background tasks", map[string]string{"addr": server.Addr})
 
+		close(stopPurge)
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
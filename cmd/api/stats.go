@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// maxCachedStats bounds the number of distinct queries whose statistics are
+// kept in the cache.
+const maxCachedStats = 1000
+
+type cachedStats struct {
+	stats   *data.MovieStats
+	expires time.Time
+}
+
+// statsCache keeps the statistics of recent queries in memory. Its zero
+// value is an empty cache.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedStats
+}
+
+// get returns a copy of the cached statistics, which the caller is free to
+// change.
+func (c *statsCache) get(key string) (*data.MovieStats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+
+	stats := *entry.stats
+	return &stats, true
+}
+
+// set caches a copy of the statistics, so that the caller can keep changing
+// them.
+func (c *statsCache) set(key string, stats *data.MovieStats, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.entries) >= maxCachedStats {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+
+	// When every entry is still fresh, the cache starts over rather than
+	// growing without bounds.
+	if c.entries == nil || len(c.entries) >= maxCachedStats {
+		c.entries = make(map[string]cachedStats)
+	}
+
+	cached := *stats
+	c.entries[key] = cachedStats{stats: &cached, expires: now.Add(ttl)}
+}
+
+func (app *application) movieStatsHandler(w http.ResponseWriter, r *http.Request) {
+	var q data.MovieQuery
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	q.Title = qs.GetString("title", "")
+	q.Genres = qs.GetCSV("genres", []string{})
+	q.Language = app.config.search.language
+	q.Statuses = qs.GetCSV("status", []string{})
+
+	publishedOnly, err := app.readsPublishedOnly(r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if publishedOnly {
+		q.Statuses = []string{data.MovieStatusPublished}
+	}
+
+	if data.ValidateMovieQuery(v, q); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	key := strings.Join([]string{
+		q.Title, strings.Join(q.Genres, ","), strings.Join(q.Statuses, ","),
+	}, "\x00")
+
+	stats, ok := app.stats.get(key)
+	if !ok {
+		stats, err = app.models.Movies.Stats(r.Context(), q)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.stats.set(key, stats, app.config.stats.cacheTTL)
+	}
+
+	stats.Runtime.Format = app.contextGetRuntimeFormat(r)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"stats": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestStatsCacheCopies(t *testing.T) {
+	var c statsCache
+
+	stats := &data.MovieStats{Total: 4}
+	c.set("all", stats, time.Minute)
+	stats.Total = 5
+
+	cached, ok := c.get("all")
+	if !ok || cached.Total != 4 {
+		t.Fatalf("expected the stats as they were set, got %+v", cached)
+	}
+
+	cached.Total = 6
+	if cached, _ := c.get("all"); cached.Total != 4 {
+		t.Errorf("expected changes to a returned copy to be ignored, got %+v", cached)
+	}
+}
+
+func TestStatsCacheEviction(t *testing.T) {
+	t.Run("expired entries", func(t *testing.T) {
+		var c statsCache
+
+		for i := 0; i < maxCachedStats; i++ {
+			ttl := time.Minute
+			if i%2 == 0 {
+				ttl = -time.Minute
+			}
+			c.set(fmt.Sprint(i), &data.MovieStats{}, ttl)
+		}
+
+		c.set("new", &data.MovieStats{}, time.Minute)
+
+		if got, expected := len(c.entries), maxCachedStats/2+1; got != expected {
+			t.Errorf("expected %d entries, got %d", expected, got)
+		}
+		if _, ok := c.get("1"); !ok {
+			t.Error("expected the fresh entries to be kept")
+		}
+	})
+
+	t.Run("fresh entries", func(t *testing.T) {
+		var c statsCache
+
+		for i := 0; i < maxCachedStats; i++ {
+			c.set(fmt.Sprint(i), &data.MovieStats{}, time.Minute)
+		}
+
+		c.set("new", &data.MovieStats{}, time.Minute)
+
+		if len(c.entries) != 1 {
+			t.Errorf("expected the cache to start over, got %d entries", len(c.entries))
+		}
+		if _, ok := c.get("new"); !ok {
+			t.Error("expected the new entry to be cached")
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// addMovieTagsHandler tags a movie. The tags that do not exist yet are
+// created pending, unless the user can publish movies, in which case they
+// are approved right away.
+func (app *application) addMovieTagsHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Tags []string `json:"tags"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	for i, name := range input.Tags {
+		input.Tags[i] = data.NormalizeTag(name)
+	}
+
+	v := validator.New()
+	v.Check(len(input.Tags) > 0, "tags", "must contain at least 1 tag")
+	v.Check(len(input.Tags) <= data.MaxMovieTags, "tags", "must not contain more than 20 tags")
+	v.Check(validator.Unique(input.Tags), "tags", "must not contain duplicate values")
+	for _, name := range input.Tags {
+		data.ValidateTagName(v, "tags", name)
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	approve, err := app.hasPermission(r, "movies:publish")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tags.AddToMovie(r.Context(), movie.ID, input.Tags, app.contextGetUser(r).ID, approve)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTagRejected):
+			v.AddError("tags", "must not contain rejected tags")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrTooManyTags):
+			v.AddError("tags", "the movie must not have more than 20 tags")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	tags, err := app.models.Tags.GetAllForMovie(r.Context(), movie.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteMovieTagHandler(w http.ResponseWriter, r *http.Request) {
+	movie, ok := app.getMovieChildrenForUpdate(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Tags.RemoveFromMovie(r.Context(), movie.ID, data.NormalizeTag(chi.URLParam(r, "tag")))
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "tag successfully removed"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) autocompleteTagsHandler(w http.ResponseWriter, r *http.Request) {
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	prefix := data.NormalizeTag(strings.TrimSpace(qs.GetString("q", "")))
+	limit, err := qs.GetInt("limit", 10)
+	if err != nil {
+		v.AddError("limit", "invalid query param, must be integer")
+	}
+
+	v.Check(prefix != "", "q", "must be provided")
+	v.Check(len(prefix) <= 50, "q", "must not be longer than 50 bytes")
+	v.Check(limit > 0, "limit", "must be greater than zero")
+	v.Check(limit <= 20, "limit", "must be a maximum of 20")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tags, err := app.models.Tags.Autocomplete(r.Context(), prefix, limit)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// listTagsHandler lists the tags to moderate, the pending ones by default.
+func (app *application) listTagsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		filters data.Filters
+		err     error
+	)
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	status := qs.GetString("status", data.TagStatusPending)
+	v.Check(
+		status == "all" || validator.In(status, data.TagStatuses...),
+		"status",
+		"must be pending, approved, rejected or all",
+	)
+	if status == "all" {
+		status = ""
+	}
+
+	if filters.Page, err = qs.GetInt("page", defaultPageNum); err != nil {
+		v.AddError("page", "invalid query param, must be integer")
+	}
+	if filters.PageSize, err = qs.GetInt("page_size", defaultPageSize); err != nil {
+		v.AddError("page_size", "invalid query param, must be integer")
+	}
+	filters.Sort = qs.GetString("sort", "id")
+	filters.SortSafelist = []string{"id", "name", "-id", "-name"}
+
+	if filters.Validate(v); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tags, meta, err := app.models.Tags.GetAll(r.Context(), status, filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tags": tags, "metadata": meta}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) updateTagStatusHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Status string `json:"status"`
+	}
+
+	if err := app.readJSON(w, r, &input); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if v.Check(validator.In(input.Status, data.TagStatuses...), "status", "unsupported status"); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	tag, err := app.models.Tags.SetStatus(r.Context(), id, input.Status)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tag": tag}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestMovieTagsUpdatePermissions(t *testing.T) {
+	tags := map[string][]string{"tags": {"ocean"}}
+
+	tests := []struct {
+		desc           string
+		publisher      bool
+		method         string
+		urlPath        string
+		body           any
+		expectedStatus int
+	}{
+		{"writer tags published", false, http.MethodPost, "/1/tags", tags, http.StatusForbidden},
+		{"writer tags draft", false, http.MethodPost, "/2/tags", tags, http.StatusOK},
+		{"publisher tags published", true, http.MethodPost, "/1/tags", tags, http.StatusOK},
+		{"writer untags published", false, http.MethodDelete, "/1/tags/pixar", nil, http.StatusForbidden},
+		{"writer untags draft", false, http.MethodDelete, "/2/tags/pixar", nil, http.StatusOK},
+		{"publisher untags published", true, http.MethodDelete, "/1/tags/pixar", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			app, ts := newTestEnv(t, "Moana", "Untitled")
+			token := newTestEditor(t, app, tt.publisher)
+
+			for _, movieID := range []int64{1, 2} {
+				err := app.models.Tags.AddToMovie(context.Background(), movieID, []string{"pixar"}, 0, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			res := ts.do(t, tt.method, "/api/v1/movies"+tt.urlPath, token, tt.body, nil)
+			if res.status != tt.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.expectedStatus, res.status, res.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/jsonlog"
+	"github.com/zmwilliam/greenlight/internal/storage"
+)
+
+// mockMailer records the emails instead of sending them.
+type mockMailer struct {
+	mu   sync.Mutex
+	sent []sentEmail
+}
+
+type sentEmail struct {
+	recipient    string
+	templateFile string
+	data         interface{}
+}
+
+func (m *mockMailer) Send(recipient, templateFile string, data interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, sentEmail{recipient, templateFile, data})
+	return nil
+}
+
+func (m *mockMailer) emails() []sentEmail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]sentEmail(nil), m.sent...)
+}
+
+// newTestApplication returns an application backed by the memory models, a
+// mock mailer and a temporary image storage, without rate limiting.
+func newTestApplication(t *testing.T) *application {
+	t.Helper()
+
+	var cfg config
+	cfg.env = "test"
+	cfg.cursor.secret = "test-cursor-secret"
+	cfg.search.language = "en"
+	cfg.storage.baseURL = "/images"
+	cfg.stats.cacheTTL = time.Minute
+	cfg.imports.maxBytes = 1024 * 1024
+	cfg.imports.syncMaxBytes = 1024 * 1024
+	cfg.images.maxBytes = 1024 * 1024
+
+	store, err := storage.NewLocal(t.TempDir(), cfg.storage.baseURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &application{
+		config:  cfg,
+		logger:  jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		models:  data.NewMemoryModels(),
+		mailer:  &mockMailer{},
+		storage: store,
+		stats:   &statsCache{},
+	}
+}
+
+// testMovies holds the movies the handler tests start from, by title.
+var testMovies = map[string]data.Movie{
+	"Moana":              {Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}},
+	"Black Panther":      {Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action"}},
+	"Deadpool":           {Title: "Deadpool", Year: 2016, Runtime: 108, Genres: []string{"action", "comedy"}},
+	"The Breakfast Club": {Title: "The Breakfast Club", Year: 1985, Runtime: 96, Genres: []string{"drama"}},
+	"Untitled": {
+		Title: "Untitled", Year: 2024, Runtime: 90, Genres: []string{"drama"}, Status: data.MovieStatusDraft,
+	},
+}
+
+// newTestEnv returns a test application, with the named testMovies inserted
+// in order so that their ids follow it, and a server for its routes. The
+// movies are published unless they say otherwise.
+func newTestEnv(t *testing.T, titles ...string) (*application, *testServer) {
+	t.Helper()
+
+	app := newTestApplication(t)
+
+	for _, title := range titles {
+		movie, ok := testMovies[title]
+		if !ok {
+			t.Fatalf("no test movie titled %q", title)
+		}
+
+		movie.Genres = slices.Clone(movie.Genres)
+		if movie.Status == "" {
+			movie.Status = data.MovieStatusPublished
+		}
+
+		if err := app.models.Movies.Insert(context.Background(), &movie, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return app, newTestServer(t, app.routes())
+}
+
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+
+	return &testServer{ts}
+}
+
+type testResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+// decode unmarshals the body of the response into dest.
+func (r testResponse) decode(t *testing.T, dest any) {
+	t.Helper()
+
+	if err := json.Unmarshal(r.body, dest); err != nil {
+		t.Fatalf("cannot decode response %q: %s", r.body, err)
+	}
+}
+
+// do sends a request with body encoded as JSON, or sent as is when it is a
+// []byte, authenticated by the token unless it is empty.
+func (ts *testServer) do(
+	t *testing.T,
+	method, urlPath, token string,
+	body any,
+	headers http.Header,
+) testResponse {
+	t.Helper()
+
+	var reader io.Reader
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		reader = bytes.NewReader(body)
+	default:
+		js, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(js)
+	}
+
+	req, err := http.NewRequest(method, ts.URL+urlPath, reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testResponse{res.StatusCode, res.Header, resBody}
+}
+
+// newTestUser inserts an activated user with the permissions and returns an
+// authentication token for them.
+func newTestUser(t *testing.T, app *application, email string, permissions ...string) string {
+	t.Helper()
+
+	ctx := context.Background()
+
+	user := &data.User{Name: "Test User", Email: email, Activated: true}
+	if err := app.models.Users.Insert(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.models.Permissions.AddForUser(ctx, user.ID, permissions...); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := app.models.Tokens.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token.Plaintext
+}
+
+// newTestEditor inserts a user who can edit movies, and publish them when
+// publisher is true, and returns their authentication token.
+func newTestEditor(t *testing.T, app *application, publisher bool) string {
+	t.Helper()
+
+	if publisher {
+		return newTestUser(t, app, "publisher@example.com", "movies:read", "movies:write", "movies:publish")
+	}
+	return newTestUser(t, app, "writer@example.com", "movies:read", "movies:write")
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func (app *application) listTrashedMoviesHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		filters data.Filters
+		err     error
+	)
+
+	v := validator.New()
+	qs := NewQueryParams(r)
+
+	if filters.Page, err = qs.GetInt("page", defaultPageNum); err != nil {
+		v.AddError("page", "invalid query param, must be integer")
+	}
+	if filters.PageSize, err = qs.GetInt("page_size", defaultPageSize); err != nil {
+		v.AddError("page_size", "invalid query param, must be integer")
+	}
+	filters.Sort = qs.GetString("sort", "-deleted_at")
+	filters.SortSafelist = []string{
+		"id", "title", "deleted_at", "-id", "-title", "-deleted_at",
+	}
+
+	if filters.Validate(v); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	movies, meta, err := app.models.Movies.GetAllDeleted(r.Context(), filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.formatMovies(r, movies...)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movies": movies, "metadata": meta}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) restoreMovieHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	movie, err := app.models.Movies.Restore(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.formatMovies(r, movie)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// purgeTrash periodically removes the movies that have been in the trash for
+// longer than the configured retention period, until stop is closed.
+func (app *application) purgeTrash(stop <-chan struct{}) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		ticker := time.NewTicker(app.config.trash.purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			before := time.Now().Add(-app.config.trash.retention)
+
+			purged, keys, err := app.models.Movies.PurgeDeleted(context.Background(), before)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+				continue
+			}
+
+			app.deleteStoredImages(keys...)
+
+			if purged > 0 {
+				app.logger.PrintInfo("purged trashed movies", map[string]string{
+					"count": strconv.FormatInt(purged, 10),
+				})
+			}
+		}
+	}()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestTrashDeleteRestorePurge(t *testing.T) {
+	app, ts := newTestEnv(t, "Moana")
+
+	token := newTestEditor(t, app, true)
+
+	trashedTitles := func() []string {
+		t.Helper()
+
+		res := ts.do(t, http.MethodGet, "/api/v1/movies/trash", token, nil, nil)
+		if res.status != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+		}
+
+		var body struct {
+			Movies []*data.Movie `json:"movies"`
+		}
+		res.decode(t, &body)
+
+		titles := []string{}
+		for _, movie := range body.Movies {
+			titles = append(titles, movie.Title)
+		}
+		return titles
+	}
+
+	steps := []struct {
+		method         string
+		urlPath        string
+		expectedStatus int
+	}{
+		{http.MethodDelete, "/api/v1/movies/1", http.StatusCreated},
+		{http.MethodGet, "/api/v1/movies/1", http.StatusNotFound},
+		{http.MethodPost, "/api/v1/movies/1/restore", http.StatusOK},
+		{http.MethodGet, "/api/v1/movies/1", http.StatusOK},
+		{http.MethodDelete, "/api/v1/movies/1", http.StatusCreated},
+	}
+
+	for _, step := range steps {
+		res := ts.do(t, step.method, step.urlPath, token, nil, nil)
+		if res.status != step.expectedStatus {
+			t.Fatalf("%s %s: expected status %d, got %d: %s",
+				step.method, step.urlPath, step.expectedStatus, res.status, res.body)
+		}
+	}
+
+	if titles := trashedTitles(); len(titles) != 1 || titles[0] != "Moana" {
+		t.Fatalf("expected Moana in the trash, got %v", titles)
+	}
+
+	app.config.trash.retention = 0
+	app.config.trash.purgeInterval = time.Millisecond
+
+	stop := make(chan struct{})
+	app.purgeTrash(stop)
+
+	for deadline := time.Now().Add(time.Second); len(trashedTitles()) > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the trash to be purged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(stop)
+	app.wg.Wait()
+
+	res := ts.do(t, http.MethodPost, "/api/v1/movies/1/restore", token, nil, nil)
+	if res.status != http.StatusNotFound {
+		t.Errorf("expected status %d after the purge, got %d: %s", http.StatusNotFound, res.status, res.body)
+	}
+}
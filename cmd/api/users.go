@@ -41,7 +41,23 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Users.Insert(user)
+	// The user is only created along with their permissions and activation
+	// token.
+	var token *data.Token
+
+	err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+		if err := tx.Users.Insert(r.Context(), user); err != nil {
+			return err
+		}
+
+		if err := tx.Permissions.AddForUser(r.Context(), user.ID, "movies:read"); err != nil {
+			return err
+		}
+
+		var err error
+		token, err = tx.Tokens.New(r.Context(), user.ID, 3*24*time.Hour, data.ScopeActivation)
+		return err
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
@@ -55,25 +71,13 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Permissions.AddForUser(user.ID, "movies:read")
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	token, err := app.models.Tokens.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
 	app.background(func() {
 		data := map[string]interface{}{
 			"activationToken": token.Plaintext,
 			"userID":          user.ID,
 		}
 
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
+		err := app.mailer.Send(user.Email, "user_welcome.tmpl", data)
 		if err != nil {
 			app.logger.PrintError(err, nil)
 		}
@@ -103,7 +107,7 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+	user, err := app.models.Users.GetForToken(r.Context(), data.ScopeActivation, input.TokenPlaintext)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -117,7 +121,13 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 	user.Activated = true
 
-	err = app.models.Users.Update(user)
+	err = app.models.WithTx(r.Context(), func(tx data.Models) error {
+		if err := tx.Users.Update(r.Context(), user); err != nil {
+			return err
+		}
+
+		return tx.Tokens.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -128,12 +138,6 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	err = app.models.Tokens.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegisterUserHandler(t *testing.T) {
+	app, ts := newTestEnv(t)
+
+	res := ts.do(t, http.MethodPost, "/api/v1/users", "", map[string]string{
+		"name":     "Alice",
+		"email":    "alice@example.com",
+		"password": "pa55word1234",
+	}, nil)
+	if res.status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+	}
+
+	tests := []struct {
+		desc     string
+		input    map[string]string
+		expected map[string]string
+	}{
+		{
+			desc:     "duplicate email",
+			input:    map[string]string{"name": "Alice", "email": "alice@example.com", "password": "pa55word1234"},
+			expected: map[string]string{"email": "a user with this email address already exists"},
+		},
+		{
+			desc:     "duplicate email in another case",
+			input:    map[string]string{"name": "Alice", "email": "ALICE@example.com", "password": "pa55word1234"},
+			expected: map[string]string{"email": "a user with this email address already exists"},
+		},
+		{
+			desc:     "short password",
+			input:    map[string]string{"name": "Bob", "email": "bob@example.com", "password": "short"},
+			expected: map[string]string{"password": "must be at least 8 bytes long"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			res := ts.do(t, http.MethodPost, "/api/v1/users", "", tt.input, nil)
+			if res.status != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.status, res.body)
+			}
+
+			var body struct {
+				Error map[string]string `json:"error"`
+			}
+			res.decode(t, &body)
+
+			if diff := cmp.Diff(tt.expected, body.Error); diff != "" {
+				t.Errorf("errors does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+
+	app.wg.Wait()
+
+	emails := app.mailer.(*mockMailer).emails()
+	if len(emails) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(emails))
+	}
+	if emails[0].recipient != "alice@example.com" || emails[0].templateFile != "user_welcome.tmpl" {
+		t.Errorf("unexpected email %+v", emails[0])
+	}
+}
+
+func TestActivateUserHandler(t *testing.T) {
+	app, ts := newTestEnv(t)
+
+	res := ts.do(t, http.MethodPost, "/api/v1/users", "", map[string]string{
+		"name":     "Alice",
+		"email":    "alice@example.com",
+		"password": "pa55word1234",
+	}, nil)
+	if res.status != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, res.status, res.body)
+	}
+
+	app.wg.Wait()
+
+	emails := app.mailer.(*mockMailer).emails()
+	if len(emails) != 1 {
+		t.Fatalf("expected 1 email, got %d", len(emails))
+	}
+	token := emails[0].data.(map[string]interface{})["activationToken"].(string)
+
+	res = ts.do(t, http.MethodPut, "/api/v1/users/activated", "", map[string]string{"token": token}, nil)
+	if res.status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, res.status, res.body)
+	}
+
+	var body struct {
+		User struct {
+			Email     string `json:"email"`
+			Activated bool   `json:"activated"`
+		} `json:"user"`
+	}
+	res.decode(t, &body)
+
+	if !body.User.Activated {
+		t.Errorf("expected user %s to be activated", body.User.Email)
+	}
+
+	// Activation tokens can only be used once.
+	res = ts.do(t, http.MethodPut, "/api/v1/users/activated", "", map[string]string{"token": token}, nil)
+	if res.status != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, res.status, res.body)
+	}
+}
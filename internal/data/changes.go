@@ -0,0 +1,388 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Only published movies are visible to readers. Draft movies are worked on
+// by writers until they submit them for review, which creates a publish
+// change.
+const (
+	MovieStatusDraft         = "draft"
+	MovieStatusPendingReview = "pending_review"
+	MovieStatusPublished     = "published"
+	MovieStatusArchived      = "archived"
+)
+
+var MovieStatuses = []string{
+	MovieStatusDraft,
+	MovieStatusPendingReview,
+	MovieStatusPublished,
+	MovieStatusArchived,
+}
+
+const (
+	ChangeKindPublish = "publish"
+	ChangeKindEdit    = "edit"
+)
+
+const (
+	ChangeStatusPending  = "pending"
+	ChangeStatusApproved = "approved"
+	ChangeStatusRejected = "rejected"
+)
+
+var ChangeStatuses = []string{ChangeStatusPending, ChangeStatusApproved, ChangeStatusRejected}
+
+var ErrChangeReviewed = errors.New("change already reviewed")
+
+// MovieChange is a change of a movie waiting for, or having gone through,
+// review. It holds the movie fields as proposed, based on BaseVersion. A
+// publish change proposes the draft as it was submitted.
+type MovieChange struct {
+	ID          int64      `json:"id"`
+	MovieID     int64      `json:"movie_id"`
+	Kind        string     `json:"kind"`
+	BaseVersion int32      `json:"base_version"`
+	Title       string     `json:"title"`
+	Year        int32      `json:"year"`
+	Runtime     Runtime    `json:"runtime"`
+	Genres      []string   `json:"genres"`
+	Status      string     `json:"status"`
+	UserID      int64      `json:"user_id,omitempty"`
+	ReviewerID  int64      `json:"reviewer_id,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+
+	// RuntimeFormat is the format the runtime is written in, the default
+	// one when empty.
+	RuntimeFormat string `json:"-"`
+}
+
+func (c MovieChange) MarshalJSON() ([]byte, error) {
+	type change MovieChange
+	return json.Marshal(struct {
+		change
+		Runtime any `json:"runtime"`
+	}{change(c), c.Runtime.Format(c.RuntimeFormat)})
+}
+
+// NewMovieChange proposes the current fields of the movie, as edited by the
+// given user.
+func NewMovieChange(kind string, movie *Movie, userID int64) *MovieChange {
+	return &MovieChange{
+		MovieID:     movie.ID,
+		Kind:        kind,
+		BaseVersion: movie.Version,
+		Title:       movie.Title,
+		Year:        movie.Year,
+		Runtime:     movie.Runtime,
+		Genres:      slices.Clone(movie.Genres),
+		Status:      ChangeStatusPending,
+		UserID:      userID,
+	}
+}
+
+// Apply copies the proposed values onto the movie.
+func (c *MovieChange) Apply(movie *Movie) {
+	movie.Title = c.Title
+	movie.Year = c.Year
+	movie.Runtime = c.Runtime
+	movie.Genres = slices.Clone(c.Genres)
+}
+
+const movieChangeColumns = `id, movie_id, kind, base_version, title, year, runtime, genres,
+	status, user_id, reviewer_id, comment, created_at, reviewed_at`
+
+// scanMovieChange scans a row of movieChangeColumns, preceded by the
+// leading columns, if any.
+func scanMovieChange(row interface{ Scan(...any) error }, leading ...any) (*MovieChange, error) {
+	var (
+		change             MovieChange
+		userID, reviewerID sql.NullInt64
+	)
+
+	err := row.Scan(append(leading,
+		&change.ID,
+		&change.MovieID,
+		&change.Kind,
+		&change.BaseVersion,
+		&change.Title,
+		&change.Year,
+		&change.Runtime,
+		pq.Array(&change.Genres),
+		&change.Status,
+		&userID,
+		&reviewerID,
+		&change.Comment,
+		&change.CreatedAt,
+		&change.ReviewedAt,
+	)...)
+	if err != nil {
+		return nil, err
+	}
+
+	change.UserID = userID.Int64
+	change.ReviewerID = reviewerID.Int64
+
+	return &change, nil
+}
+
+// SubmitChange records the change for review. A publish change also moves
+// the draft to pending review, failing with ErrEditConflict when the movie
+// is no longer the submitted draft.
+func (m MovieModel) SubmitChange(ctx context.Context, change *MovieChange) error {
+	query := `
+	INSERT INTO movie_changes (movie_id, kind, base_version, title, year, runtime, genres, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	RETURNING id, status, created_at`
+
+	args := []any{
+		change.MovieID,
+		change.Kind,
+		change.BaseVersion,
+		change.Title,
+		change.Year,
+		change.Runtime,
+		pq.Array(change.Genres),
+		sql.NullInt64{Int64: change.UserID, Valid: change.UserID > 0},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		if change.Kind == ChangeKindPublish {
+			err := execOne(ctx, tx, `
+			UPDATE movies SET status = $1
+			WHERE id = $2 AND version = $3 AND status = $4 AND deleted_at IS NULL`,
+				MovieStatusPendingReview, change.MovieID, change.BaseVersion, MovieStatusDraft,
+			)
+			if err != nil {
+				if errors.Is(err, ErrRecordNotFound) {
+					return ErrEditConflict
+				}
+				return err
+			}
+		}
+
+		return tx.QueryRowContext(ctx, query, args...).Scan(&change.ID, &change.Status, &change.CreatedAt)
+	})
+}
+
+// SetStatus changes the status of the movie, failing with ErrEditConflict
+// when the movie is no longer at the same version. Status changes do not
+// create a new version.
+func (m MovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	query := `
+	UPDATE movies SET status = $1
+	WHERE id = $2 AND version = $3 AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := execOne(ctx, m.DB, query, status, movie.ID, movie.Version)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return ErrEditConflict
+		}
+		return err
+	}
+
+	movie.Status = status
+	return nil
+}
+
+type MovieChangeModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+func (m MovieChangeModel) Get(ctx context.Context, id int64) (*MovieChange, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `SELECT ` + movieChangeColumns + ` FROM movie_changes WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	change, err := scanMovieChange(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return change, nil
+}
+
+// GetAll lists the changes with the given status, or all of them when it
+// is empty.
+func (m MovieChangeModel) GetAll(
+	ctx context.Context,
+	status string,
+	filters Filters,
+) ([]*MovieChange, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM movie_changes
+	WHERE status = $1 OR $1 = ''
+	ORDER BY %s
+	LIMIT $2 OFFSET $3`, movieChangeColumns, filters.OrderBy())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	changes := []*MovieChange{}
+	for rows.Next() {
+		change, err := scanMovieChange(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		changes = append(changes, change)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := newMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return changes, metadata, nil
+}
+
+// Approve applies the pending change and marks it as approved by the
+// reviewer. Edits are saved as a new version attributed to their author,
+// while publish changes publish the movie. It fails with ErrEditConflict
+// when the movie changed since the change was made.
+func (m MovieChangeModel) Approve(
+	ctx context.Context,
+	id, reviewerID int64,
+	comment string,
+) (*MovieChange, *Movie, error) {
+	var movie *Movie
+
+	change, err := m.review(ctx, id, func(ctx context.Context, tx DBTX, change *MovieChange) error {
+		movies := MovieModel{DB: tx, Timeout: m.Timeout}
+
+		var err error
+		if movie, err = movies.Get(ctx, change.MovieID); err != nil {
+			return err
+		}
+
+		if movie.Version != change.BaseVersion {
+			return ErrEditConflict
+		}
+
+		change.Status = ChangeStatusApproved
+
+		if change.Kind == ChangeKindPublish {
+			return movies.SetStatus(ctx, movie, MovieStatusPublished)
+		}
+
+		change.Apply(movie)
+		return movies.Update(ctx, movie, change.UserID)
+	}, reviewerID, comment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return change, movie, nil
+}
+
+// Reject marks the pending change as rejected by the reviewer. A rejected
+// publish change moves the movie back to draft.
+func (m MovieChangeModel) Reject(
+	ctx context.Context,
+	id, reviewerID int64,
+	comment string,
+) (*MovieChange, error) {
+	return m.review(ctx, id, func(ctx context.Context, tx DBTX, change *MovieChange) error {
+		change.Status = ChangeStatusRejected
+
+		if change.Kind != ChangeKindPublish {
+			return nil
+		}
+
+		_, err := tx.ExecContext(ctx, `
+		UPDATE movies SET status = $1 WHERE id = $2 AND status = $3`,
+			MovieStatusDraft, change.MovieID, MovieStatusPendingReview,
+		)
+		return err
+	}, reviewerID, comment)
+}
+
+// review locks the pending change, lets decide set its status and update the
+// movie accordingly, then saves the review, all in one transaction.
+func (m MovieChangeModel) review(
+	ctx context.Context,
+	id int64,
+	decide func(ctx context.Context, tx DBTX, change *MovieChange) error,
+	reviewerID int64,
+	comment string,
+) (*MovieChange, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var change *MovieChange
+
+	err := inTx(ctx, m.DB, func(tx DBTX) error {
+		query := `SELECT ` + movieChangeColumns + ` FROM movie_changes WHERE id = $1 FOR UPDATE`
+
+		var err error
+		if change, err = scanMovieChange(tx.QueryRowContext(ctx, query, id)); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+
+		if change.Status != ChangeStatusPending {
+			return ErrChangeReviewed
+		}
+
+		if err = decide(ctx, tx, change); err != nil {
+			return err
+		}
+
+		change.ReviewerID = reviewerID
+		change.Comment = comment
+
+		return tx.QueryRowContext(ctx, `
+		UPDATE movie_changes SET status = $1, reviewer_id = $2, comment = $3, reviewed_at = NOW()
+		WHERE id = $4
+		RETURNING reviewed_at`,
+			change.Status, reviewerID, comment, change.ID,
+		).Scan(&change.ReviewedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
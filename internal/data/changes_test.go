@@ -0,0 +1,47 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestMovieChangeApply(t *testing.T) {
+	edited := &data.Movie{
+		ID:      1,
+		Title:   "Moana",
+		Year:    2016,
+		Runtime: 107,
+		Genres:  []string{"animation", "adventure"},
+		Version: 3,
+		Status:  data.MovieStatusPublished,
+	}
+
+	change := data.NewMovieChange(data.ChangeKindEdit, edited, 7)
+
+	if change.BaseVersion != 3 || change.UserID != 7 || change.Status != data.ChangeStatusPending {
+		t.Fatalf("unexpected change %+v", change)
+	}
+
+	// The proposed genres must not share memory with the edited movie.
+	edited.Genres[0] = "family"
+
+	current := &data.Movie{ID: 1, Title: "Moana", Year: 2015, Version: 3, Status: data.MovieStatusPublished}
+	change.Apply(current)
+
+	expected := &data.Movie{
+		ID:      1,
+		Title:   "Moana",
+		Year:    2016,
+		Runtime: 107,
+		Genres:  []string{"animation", "adventure"},
+		Version: 3,
+		Status:  data.MovieStatusPublished,
+	}
+
+	if diff := cmp.Diff(expected, current); diff != "" {
+		t.Errorf("movie does not match (-want, +got):\n%s", diff)
+	}
+}
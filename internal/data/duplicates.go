@@ -0,0 +1,147 @@
+package data
+
+import (
+	"context"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// FindDuplicate returns the oldest movie released the same year with the
+// same title, ignoring case, spaces and punctuation.
+func (m MovieModel) FindDuplicate(ctx context.Context, title string, year int32) (*Movie, error) {
+	query := `
+	SELECT ` + movieColumns + `
+	FROM movies
+	WHERE normalize_title(title) = normalize_title($1) AND year = $2 AND deleted_at IS NULL
+	ORDER BY id ASC
+	LIMIT 1`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanMovie(m.DB.QueryRowContext(ctx, query, title, year))
+}
+
+// mergeStatements move the children of the source movie ($2) to the target
+// movie ($1), unless the target has its own.
+var mergeStatements = []string{
+	`UPDATE movie_external_ids SET movie_id = $1 WHERE movie_id = $2`,
+	`INSERT INTO movie_titles (movie_id, locale, title)
+	SELECT $1, locale, title FROM movie_titles WHERE movie_id = $2
+	ON CONFLICT DO NOTHING`,
+	`INSERT INTO movie_releases (movie_id, country, release_date, certification)
+	SELECT $1, country, release_date, certification FROM movie_releases WHERE movie_id = $2
+	ON CONFLICT DO NOTHING`,
+	`UPDATE movie_images SET movie_id = $1
+	WHERE movie_id = $2 AND kind NOT IN (SELECT kind FROM movie_images WHERE movie_id = $1)`,
+	`INSERT INTO movie_ratings (user_id, movie_id, rating, updated_at)
+	SELECT user_id, $1, rating, updated_at FROM movie_ratings WHERE movie_id = $2
+	ON CONFLICT DO NOTHING`,
+	`INSERT INTO movie_tags (movie_id, tag_id)
+	SELECT $1, tag_id FROM movie_tags WHERE movie_id = $2
+	ON CONFLICT DO NOTHING`,
+}
+
+// Merge combines the source movie into the target movie and permanently
+// deletes the source. The target keeps its fields and children, and takes
+// the external ids of the source along with the genres, alternate titles,
+// releases, images, ratings and tags it does not have. A revision attributed to
+// the given user is recorded when its genres change.
+//
+// It returns the merged movie and the storage keys of the source images
+// that were dropped.
+func (m MovieModel) Merge(ctx context.Context, targetID, sourceID, userID int64) (*Movie, []string, error) {
+	if targetID < 1 || sourceID < 1 || targetID == sourceID {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	var (
+		merged  *Movie
+		dropped []string
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, batchContextTimeout)
+	defer cancel()
+
+	err := inTx(ctx, m.DB, func(tx DBTX) error {
+		// Lock both movies in id order, so that concurrent merges of the same
+		// movies do not deadlock.
+		rows, err := tx.QueryContext(ctx, `
+		SELECT id, genres FROM movies
+		WHERE id IN ($1, $2) AND deleted_at IS NULL
+		ORDER BY id
+		FOR UPDATE`, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var (
+			found        int
+			sourceGenres []string
+		)
+		for rows.Next() {
+			var (
+				id     int64
+				genres []string
+			)
+			if err := rows.Scan(&id, pq.Array(&genres)); err != nil {
+				return err
+			}
+			if id == sourceID {
+				sourceGenres = genres
+			}
+			found++
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+		if found != 2 {
+			return ErrRecordNotFound
+		}
+
+		for _, statement := range mergeStatements {
+			if _, err = tx.ExecContext(ctx, statement, targetID, sourceID); err != nil {
+				return err
+			}
+		}
+
+		err = tx.QueryRowContext(ctx, `
+		SELECT array(
+			SELECT unnest(array[key, thumbnail_key]) FROM movie_images WHERE movie_id = $1
+		)`, sourceID).Scan(pq.Array(&dropped))
+		if err != nil {
+			return err
+		}
+
+		if _, err = tx.ExecContext(ctx, `DELETE FROM movies WHERE id = $1`, sourceID); err != nil {
+			return err
+		}
+
+		movies := MovieModel{DB: tx, Timeout: m.Timeout}
+
+		if merged, err = movies.Get(ctx, targetID); err != nil {
+			return err
+		}
+
+		genres := slices.Clone(merged.Genres)
+		for _, genre := range sourceGenres {
+			if len(genres) < 5 && !slices.Contains(genres, genre) {
+				genres = append(genres, genre)
+			}
+		}
+
+		if len(genres) == len(merged.Genres) {
+			return nil
+		}
+
+		merged.Genres = genres
+		return movies.Update(ctx, merged, userID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return merged, dropped, nil
+}
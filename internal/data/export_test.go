@@ -0,0 +1,27 @@
+package data
+
+// Cursor exposes the pagination cursor to the tests of package data_test.
+type Cursor = cursor
+
+func (f Filters) EncodeCursor(c Cursor) string {
+	return f.encodeCursor(c)
+}
+
+func (f Filters) DecodeCursor() (Cursor, error) {
+	return f.decodeCursor()
+}
+
+// KeysetClause returns the keyset condition for c along with its arguments.
+func (f Filters) KeysetClause(c Cursor) (string, []any) {
+	var args queryArgs
+	clause := f.keysetClause(c, &args)
+	return clause, args
+}
+
+func CursorPage(movies []*Movie, c Cursor, f Filters, totalRecords int) ([]*Movie, Metadata) {
+	return cursorPage(movies, c, f, totalRecords)
+}
+
+func SplitPrefixTerm(search string) (string, string) {
+	return splitPrefixTerm(search)
+}
@@ -0,0 +1,108 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+var ErrDuplicateExternalID = errors.New("duplicate external id")
+
+// ExternalIDSources maps the catalogs movies can be correlated with to the
+// format of their ids.
+var ExternalIDSources = map[string]*regexp.Regexp{
+	"imdb": regexp.MustCompile(`^tt\d{7,10}$`),
+	"tmdb": regexp.MustCompile(`^\d{1,10}$`),
+}
+
+// ExternalID identifies a movie in another catalog. An external id belongs
+// to a single movie, but a movie may have several ids from the same source,
+// for instance after a merge.
+type ExternalID struct {
+	Source string `json:"source"`
+	ID     string `json:"id"`
+}
+
+type ExternalIDs []ExternalID
+
+func (e *ExternalIDs) Scan(src any) error {
+	return scanJSON(src, e)
+}
+
+// movieExternalIDsColumn selects the external ids of each movie of a query on
+// the movies table.
+const movieExternalIDsColumn = `COALESCE((
+	SELECT jsonb_agg(jsonb_build_object('source', source, 'id', external_id) ORDER BY source, external_id)
+	FROM movie_external_ids WHERE movie_id = movies.id
+), '[]') AS external_ids`
+
+func ValidateExternalID(v *validator.Validator, key string, e ExternalID) {
+	pattern, ok := ExternalIDSources[e.Source]
+	if !ok {
+		v.AddError(key, "unsupported source "+e.Source)
+		return
+	}
+
+	v.Check(pattern.MatchString(e.ID), key, "invalid "+e.Source+" id")
+}
+
+func insertExternalID(ctx context.Context, tx DBTX, movieID int64, e ExternalID) error {
+	query := `
+	INSERT INTO movie_external_ids (movie_id, source, external_id)
+	VALUES ($1, $2, $3)`
+
+	_, err := tx.ExecContext(ctx, query, movieID, e.Source, e.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_external_ids_pkey"`:
+			return ErrDuplicateExternalID
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+type MovieExternalIDModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// Insert adds the external id to the movie. It returns
+// ErrDuplicateExternalID when the id already belongs to a movie.
+func (m MovieExternalIDModel) Insert(ctx context.Context, movieID int64, e ExternalID) error {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return insertExternalID(ctx, m.DB, movieID, e)
+}
+
+func (m MovieExternalIDModel) Delete(ctx context.Context, movieID int64, e ExternalID) error {
+	query := `
+	DELETE FROM movie_external_ids
+	WHERE movie_id = $1 AND source = $2 AND external_id = $3`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return execOne(ctx, m.DB, query, movieID, e.Source, e.ID)
+}
+
+// GetByExternalID returns the movie the external id belongs to.
+func (m MovieModel) GetByExternalID(ctx context.Context, e ExternalID) (*Movie, error) {
+	query := `
+	SELECT ` + movieColumns + `
+	FROM movies
+	WHERE id = (
+		SELECT movie_id FROM movie_external_ids WHERE source = $1 AND external_id = $2
+	) AND deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanMovie(m.DB.QueryRowContext(ctx, query, e.Source, e.ID))
+}
@@ -0,0 +1,50 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func TestValidateExternalID(t *testing.T) {
+	tests := []struct {
+		desc       string
+		externalID data.ExternalID
+		expected   map[string]string
+	}{
+		{
+			desc:       "valid imdb id",
+			externalID: data.ExternalID{Source: "imdb", ID: "tt3521164"},
+			expected:   map[string]string{},
+		},
+		{
+			desc:       "valid tmdb id",
+			externalID: data.ExternalID{Source: "tmdb", ID: "277834"},
+			expected:   map[string]string{},
+		},
+		{
+			desc:       "malformed id",
+			externalID: data.ExternalID{Source: "imdb", ID: "277834"},
+			expected:   map[string]string{"id": "invalid imdb id"},
+		},
+		{
+			desc:       "unknown source",
+			externalID: data.ExternalID{Source: "letterboxd", ID: "moana-2016"},
+			expected:   map[string]string{"id": "unsupported source letterboxd"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateExternalID(v, "id", tt.externalID)
+
+			if diff := cmp.Diff(tt.expected, v.Errors); diff != "" {
+				t.Errorf("errors does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
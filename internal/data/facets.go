@@ -0,0 +1,103 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+var MovieFacetSafelist = []string{"genres", "decade", "runtime"}
+
+// movieFacetQueries holds, for each facet, a query counting the movies per
+// facet value. The %s verb is replaced with the MovieQuery conditions.
+var movieFacetQueries = map[string]string{
+	"genres": `
+		SELECT genre, count(*)
+		FROM movies CROSS JOIN unnest(genres) AS genre
+		WHERE %s
+		GROUP BY genre
+		ORDER BY count(*) DESC, genre ASC`,
+	"decade": `
+		SELECT ((year / 10) * 10)::text || 's', count(*)
+		FROM movies
+		WHERE %s
+		GROUP BY year / 10
+		ORDER BY year / 10 ASC`,
+	"runtime": `
+		SELECT CASE
+			WHEN runtime < 90 THEN '0-89'
+			WHEN runtime < 120 THEN '90-119'
+			WHEN runtime < 150 THEN '120-149'
+			ELSE '150+'
+		END AS bucket, count(*)
+		FROM movies
+		WHERE %s
+		GROUP BY bucket
+		ORDER BY min(runtime) ASC`,
+}
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type Facets map[string][]FacetCount
+
+func ValidateFacets(v *validator.Validator, facets []string) {
+	for _, facet := range facets {
+		v.Check(validator.In(facet, MovieFacetSafelist...), "facets", "invalid facet value")
+	}
+	v.Check(validator.Unique(facets), "facets", "must not contain duplicate values")
+}
+
+// Facets counts the movies matching q for each value of the given facets.
+func (m MovieModel) Facets(ctx context.Context, q MovieQuery, facets []string) (Facets, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	result := make(Facets, len(facets))
+
+	for _, facet := range facets {
+		baseQuery, ok := movieFacetQueries[facet]
+		if !ok {
+			panic("invalid facet value")
+		}
+
+		var args queryArgs
+		query := fmt.Sprintf(baseQuery, q.where(&args))
+
+		counts, err := m.queryFacet(ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+
+		result[facet] = counts
+	}
+
+	return result, nil
+}
+
+func (m MovieModel) queryFacet(ctx context.Context, query string, args ...any) ([]FacetCount, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []FacetCount{}
+	for rows.Next() {
+		var count FacetCount
+
+		if err := rows.Scan(&count.Value, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
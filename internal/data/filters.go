@@ -1,18 +1,30 @@
 package data
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"strings"
 
 	"github.com/zmwilliam/greenlight/internal/validator"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int    `json:"current_page,omitempty"`
+	PageSize     int    `json:"page_size,omitempty"`
+	FirstPage    int    `json:"first_page,omitempty"`
+	LastPage     int    `json:"last_page,omitempty"`
+	TotalRecords int    `json:"total_records,omitempty"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+	PrevCursor   string `json:"prev_cursor,omitempty"`
+	Fuzzy        bool   `json:"fuzzy,omitempty"`
 }
 
 func newMetadata(totalRecords, page, pageSize int) Metadata {
@@ -36,33 +48,42 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+
+	// UseCursor switches from LIMIT/OFFSET pagination to keyset pagination.
+	// An empty Cursor requests the first page.
+	UseCursor    bool
+	Cursor       string
+	CursorSecret []byte
+	IncludeTotal bool
 }
 
 func (f Filters) Validate(v *validator.Validator) {
-	v.Check(f.Page > 0, "page", "must be greater than zero")
-	v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	if !f.UseCursor {
+		v.Check(f.Page > 0, "page", "must be greater than zero")
+		v.Check(f.Page <= 10_000_000, "page", "must be a maximum of 10 million")
+	}
 	v.Check(f.PageSize > 0, "page_size", "must be greater than zero")
 	v.Check(f.PageSize <= 100, "page_size", "must be a maximum of 100")
-	v.Check(validator.In(f.Sort, f.SortSafelist...), "sort", "invalid sort value")
-}
 
-func (f Filters) SortValue() string {
-	val, _ := strings.CutPrefix(f.Sort, "-")
+	validSort := f.validSort()
+	v.Check(validSort, "sort", "invalid sort value")
+	v.Check(validator.Unique(f.sortColumns()), "sort", "must not contain duplicate values")
 
-	for _, v := range f.SortSafelist {
-		if val == v {
-			return v
+	if f.UseCursor && validSort {
+		for _, column := range f.sortColumns() {
+			v.Check(
+				validator.In(column, cursorSorts...),
+				"sort",
+				column+" must not be used together with cursor",
+			)
 		}
 	}
 
-	panic("invalid sort value")
-}
-
-func (f Filters) SortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+	if f.UseCursor && f.Cursor != "" && validSort {
+		c, err := f.decodeCursor()
+		v.Check(err == nil, "cursor", "invalid cursor")
+		v.Check(err != nil || c.Sort == f.Sort, "cursor", "does not match the sort value")
 	}
-	return "ASC"
 }
 
 func (f Filters) limit() int {
@@ -72,3 +93,167 @@ func (f Filters) limit() int {
 func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
+
+// descendingSorts lists the sort columns whose natural order is descending,
+// so that the unprefixed key puts the best match first.
+var descendingSorts = []string{"relevance"}
+
+// cursorSorts lists the sort columns whose values cursors can hold. Others,
+// such as the computed relevance, cannot be used in cursor mode.
+var cursorSorts = []string{"id", "title", "year", "runtime"}
+
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// validSort reports whether every comma-separated key of Sort is in the
+// safelist.
+func (f Filters) validSort() bool {
+	for _, key := range strings.Split(f.Sort, ",") {
+		if !f.safeSortKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// safeSortKey reports whether the sort key, including its direction prefix,
+// is in the safelist, so that a column can be allowed in a single direction.
+func (f Filters) safeSortKey(key string) bool {
+	return validator.In(key, f.SortSafelist...)
+}
+
+func (f Filters) sortColumns() []string {
+	keys := strings.Split(f.Sort, ",")
+	columns := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i] = strings.TrimPrefix(key, "-")
+	}
+	return columns
+}
+
+// sortKeys returns the columns the results are ordered by, always ending
+// with id so that every row has a unique position.
+func (f Filters) sortKeys() []sortKey {
+	var keys []sortKey
+	hasID := false
+
+	for _, key := range strings.Split(f.Sort, ",") {
+		if !f.safeSortKey(key) {
+			panic("invalid sort value")
+		}
+
+		column, desc := strings.CutPrefix(key, "-")
+
+		desc = desc != validator.In(column, descendingSorts...)
+		keys = append(keys, sortKey{column: column, desc: desc})
+		hasID = hasID || column == "id"
+	}
+
+	if !hasID {
+		keys = append(keys, sortKey{column: "id"})
+	}
+	return keys
+}
+
+// OrderBy returns the ORDER BY clause for the sort keys. Every column is
+// checked against the safelist, so the result is safe to interpolate into a
+// query. It panics when a column is not in the safelist.
+func (f Filters) OrderBy() string {
+	return f.orderClause(false)
+}
+
+func (f Filters) orderClause(backward bool) string {
+	keys := f.sortKeys()
+	clauses := make([]string, len(keys))
+	for i, k := range keys {
+		direction := "ASC"
+		if k.desc != backward {
+			direction = "DESC"
+		}
+		clauses[i] = fmt.Sprintf("%s %s", k.column, direction)
+	}
+	return strings.Join(clauses, ", ")
+}
+
+// keysetClause returns a condition matching the rows positioned after the
+// cursor, adding the cursor values to args.
+func (f Filters) keysetClause(c cursor, args *queryArgs) string {
+	keys := f.sortKeys()
+
+	placeholders := make([]string, len(keys))
+	for i := range keys {
+		placeholders[i] = args.add(c.Values[i])
+	}
+
+	ors := make([]string, len(keys))
+	for i, k := range keys {
+		var ands []string
+		for j := 0; j < i; j++ {
+			ands = append(ands, fmt.Sprintf("%s = %s", keys[j].column, placeholders[j]))
+		}
+
+		op := ">"
+		if k.desc != c.Backward {
+			op = "<"
+		}
+		ands = append(ands, fmt.Sprintf("%s %s %s", k.column, op, placeholders[i]))
+
+		ors[i] = "(" + strings.Join(ands, " AND ") + ")"
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")"
+}
+
+type cursor struct {
+	Sort     string `json:"s"`
+	Values   []any  `json:"v"`
+	Backward bool   `json:"b,omitempty"`
+}
+
+func (f Filters) encodeCursor(c cursor) string {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+
+	mac := hmac.New(sha256.New, f.CursorSecret)
+	mac.Write(payload)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func (f Filters) decodeCursor() (cursor, error) {
+	var c cursor
+
+	payloadPart, sigPart, found := strings.Cut(f.Cursor, ".")
+	if !found {
+		return c, ErrInvalidCursor
+	}
+
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	mac := hmac.New(sha256.New, f.CursorSecret)
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return c, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, ErrInvalidCursor
+	}
+
+	return c, nil
+}
@@ -1,6 +1,8 @@
 package data_test
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -58,6 +60,76 @@ func TestValidate(t *testing.T) {
 				"sort": "invalid sort value",
 			},
 		},
+		{
+			desc: "multiple sort values are valid",
+			input: data.Filters{
+				Page:         1,
+				PageSize:     1,
+				Sort:         "-year,title",
+				SortSafelist: []string{"title", "-year"},
+			},
+			expected_errors: map[string]string{},
+		},
+		{
+			desc: "every sort value must be in the safelist",
+			input: data.Filters{
+				Page:         1,
+				PageSize:     1,
+				Sort:         "-year,title",
+				SortSafelist: []string{"year", "title"},
+			},
+			expected_errors: map[string]string{
+				"sort": "invalid sort value",
+			},
+		},
+		{
+			desc: "sort values must not repeat a column",
+			input: data.Filters{
+				Page:         1,
+				PageSize:     1,
+				Sort:         "year,-year",
+				SortSafelist: []string{"year", "-year"},
+			},
+			expected_errors: map[string]string{
+				"sort": "must not contain duplicate values",
+			},
+		},
+		{
+			desc: "page is not required in cursor mode",
+			input: data.Filters{
+				PageSize:     1,
+				Sort:         "id",
+				SortSafelist: []string{"id"},
+				UseCursor:    true,
+			},
+			expected_errors: map[string]string{},
+		},
+		{
+			desc: "relevance cannot be used in cursor mode",
+			input: data.Filters{
+				PageSize:     1,
+				Sort:         "-relevance,id",
+				SortSafelist: []string{"id", "-relevance"},
+				UseCursor:    true,
+			},
+			expected_errors: map[string]string{
+				"sort": "relevance must not be used together with cursor",
+			},
+		},
+		{
+			desc: "cursor is invalid",
+			input: data.Filters{
+				PageSize:     1,
+				Sort:         "id",
+				SortSafelist: []string{"id"},
+				UseCursor:    true,
+				Cursor:       "eyJzIjoiaWQiLCJ2IjpbMV19.c2lnbmF0dXJl",
+				CursorSecret: []byte("secret"),
+			},
+			expected_errors: map[string]string{
+				"cursor": "invalid cursor",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -76,32 +148,70 @@ func TestValidate(t *testing.T) {
 	}
 }
 
-func TestSortValue(t *testing.T) {
-	t.Run("valid sort value without prefix", func(t *testing.T) {
-		input := data.Filters{
-			Sort:         "name",
-			SortSafelist: []string{"id", "name"},
-		}
+func TestOrderBy(t *testing.T) {
+	safelist := []string{"id", "title", "year", "relevance", "-id", "-title", "-year", "-relevance"}
 
-		expected_value := "name"
-		got := input.SortValue()
+	tests := []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc:     "ASC when value has no prefix",
+			input:    "title",
+			expected: "title ASC, id ASC",
+		},
+		{
+			desc:     "DESC when value has prefix",
+			input:    "-title",
+			expected: "title DESC, id ASC",
+		},
+		{
+			desc:     "id is not repeated as tiebreaker",
+			input:    "-id",
+			expected: "id DESC",
+		},
+		{
+			desc:     "each key has its own direction",
+			input:    "-year,title",
+			expected: "year DESC, title ASC, id ASC",
+		},
+		{
+			desc:     "relevance is descending without prefix",
+			input:    "relevance",
+			expected: "relevance DESC, id ASC",
+		},
+		{
+			desc:     "relevance is ascending with prefix",
+			input:    "-relevance",
+			expected: "relevance ASC, id ASC",
+		},
+		{
+			desc:     "id keeps its position when sorted explicitly",
+			input:    "year,-id,title",
+			expected: "year ASC, id DESC, title ASC",
+		},
+	}
 
-		if got != expected_value {
-			t.Errorf("expected %s, got %s", expected_value, got)
-		}
-	})
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			input := data.Filters{Sort: tt.input, SortSafelist: safelist}
 
-	t.Run("valid sort value with prefix", func(t *testing.T) {
-		input := data.Filters{
-			Sort:         "-name",
-			SortSafelist: []string{"id", "name"},
-		}
+			expected := tt.expected
+			got := input.OrderBy()
 
-		expected_value := "name"
-		got := input.SortValue()
+			if got != expected {
+				t.Errorf("expected %q, got %q", expected, got)
+			}
+		})
+	}
 
-		if got != expected_value {
-			t.Errorf("expected %s, got %s", expected_value, got)
+	t.Run("direction prefix is part of the safe list", func(t *testing.T) {
+		input := data.Filters{Sort: "-year,title", SortSafelist: []string{"title", "-year"}}
+
+		expected := "year DESC, title ASC, id ASC"
+		if got := input.OrderBy(); got != expected {
+			t.Errorf("expected %q, got %q", expected, got)
 		}
 	})
 
@@ -118,10 +228,10 @@ func TestSortValue(t *testing.T) {
 			SortSafelist: []string{},
 		}
 
-		input.SortValue()
+		input.OrderBy()
 	})
 
-	t.Run("panic when value not in safe list", func(t *testing.T) {
+	t.Run("panic when any value is not in safe list", func(t *testing.T) {
 		defer func() {
 			expected_reason := "invalid sort value"
 			if reason := recover(); reason != expected_reason {
@@ -130,42 +240,237 @@ func TestSortValue(t *testing.T) {
 		}()
 
 		input := data.Filters{
-			Sort:         "name",
+			Sort:         "id,name",
 			SortSafelist: []string{"id", "inserted_at"},
 		}
 
-		input.SortValue()
+		input.OrderBy()
+	})
+}
+
+func TestCursor(t *testing.T) {
+	filters := data.Filters{
+		PageSize:     2,
+		Sort:         "-year,title",
+		SortSafelist: []string{"id", "title", "-year"},
+		UseCursor:    true,
+		CursorSecret: []byte("secret"),
+	}
+
+	c := data.Cursor{Sort: "-year,title", Values: []any{2016, "Moana", 1}}
+
+	t.Run("round trip", func(t *testing.T) {
+		f := filters
+		f.Cursor = f.EncodeCursor(c)
+
+		got, err := f.DecodeCursor()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		// Numbers are decoded as json.Number so that large ids keep their
+		// precision.
+		expected := data.Cursor{
+			Sort:   "-year,title",
+			Values: []any{json.Number("2016"), "Moana", json.Number("1")},
+		}
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Errorf("cursor mismatch (-expected +got):\n%s", diff)
+		}
+
+		v := validator.New()
+		f.Validate(v)
+		if !v.Valid() {
+			t.Errorf("expected cursor to be valid, got %v", v.Errors)
+		}
 	})
+
+	invalid := []struct {
+		desc     string
+		filters  func() data.Filters
+		expected map[string]string
+	}{
+		{
+			desc: "tampered payload",
+			filters: func() data.Filters {
+				f := filters
+				_, sig, _ := strings.Cut(f.EncodeCursor(c), ".")
+				tampered := c
+				tampered.Values = []any{1900, "Moana", 1}
+				payload, _, _ := strings.Cut(f.EncodeCursor(tampered), ".")
+				f.Cursor = payload + "." + sig
+				return f
+			},
+			expected: map[string]string{"cursor": "invalid cursor"},
+		},
+		{
+			desc: "signed with another secret",
+			filters: func() data.Filters {
+				f := filters
+				f.CursorSecret = []byte("another secret")
+				f.Cursor = f.EncodeCursor(c)
+				f.CursorSecret = filters.CursorSecret
+				return f
+			},
+			expected: map[string]string{"cursor": "invalid cursor"},
+		},
+		{
+			desc: "malformed",
+			filters: func() data.Filters {
+				f := filters
+				f.Cursor = "not-a-cursor"
+				return f
+			},
+			expected: map[string]string{"cursor": "invalid cursor"},
+		},
+		{
+			desc: "reused with another sort",
+			filters: func() data.Filters {
+				f := filters
+				f.Cursor = f.EncodeCursor(c)
+				f.Sort = "title"
+				return f
+			},
+			expected: map[string]string{"cursor": "does not match the sort value"},
+		},
+	}
+
+	for _, tt := range invalid {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := validator.New()
+			tt.filters().Validate(v)
+
+			if diff := cmp.Diff(tt.expected, v.Errors); diff != "" {
+				t.Errorf("errors mismatch (-expected +got):\n%s", diff)
+			}
+		})
+	}
 }
 
-func TestSortDirection(t *testing.T) {
+func TestKeysetClause(t *testing.T) {
 	tests := []struct {
 		desc     string
-		input    string
+		sort     string
+		backward bool
+		values   []any
 		expected string
 	}{
 		{
-			desc:     "DESC when value has prefix",
-			input:    "-name",
-			expected: "DESC",
+			desc:     "ascending",
+			sort:     "title",
+			values:   []any{"Moana", 1},
+			expected: "((title > $1) OR (title = $1 AND id > $2))",
 		},
 		{
-			desc:     "ASC when value has no prefix",
-			input:    "name",
-			expected: "ASC",
+			desc:     "descending",
+			sort:     "-year",
+			values:   []any{2016, 1},
+			expected: "((year < $1) OR (year = $1 AND id > $2))",
+		},
+		{
+			desc:     "descending backward",
+			sort:     "-year",
+			backward: true,
+			values:   []any{2016, 1},
+			expected: "((year > $1) OR (year = $1 AND id < $2))",
+		},
+		{
+			desc:     "mixed directions",
+			sort:     "-year,title",
+			values:   []any{2016, "Moana", 1},
+			expected: "((year < $1) OR (year = $1 AND title > $2) OR (year = $1 AND title = $2 AND id > $3))",
+		},
+		{
+			desc:     "descending id",
+			sort:     "-id",
+			values:   []any{1},
+			expected: "((id < $1))",
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.desc, func(t *testing.T) {
-			input := data.Filters{Sort: tt.input}
+			f := data.Filters{Sort: tt.sort, SortSafelist: []string{"title", "-year", "-id"}}
 
-			expected := tt.expected
-			got := input.SortDirection()
-
-			if got != expected {
-				t.Errorf("expected %s sort direction, got %s", expected, got)
+			clause, args := f.KeysetClause(data.Cursor{Sort: tt.sort, Values: tt.values, Backward: tt.backward})
+			if clause != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, clause)
+			}
+			if diff := cmp.Diff(tt.values, args); diff != "" {
+				t.Errorf("args mismatch (-expected +got):\n%s", diff)
 			}
 		})
 	}
 }
+
+func TestCursorPage(t *testing.T) {
+	filters := data.Filters{
+		PageSize:     2,
+		Sort:         "-year",
+		SortSafelist: []string{"-year"},
+		UseCursor:    true,
+		CursorSecret: []byte("secret"),
+	}
+
+	// The query fetches one movie more than the page size to find out
+	// whether there is a next page.
+	newMovies := func() []*data.Movie {
+		return []*data.Movie{
+			{ID: 3, Title: "Encanto", Year: 2021},
+			{ID: 2, Title: "Coco", Year: 2017},
+			{ID: 1, Title: "Moana", Year: 2016},
+		}
+	}
+
+	decode := func(t *testing.T, encoded string) data.Cursor {
+		t.Helper()
+
+		f := filters
+		f.Cursor = encoded
+		c, err := f.DecodeCursor()
+		if err != nil {
+			t.Fatalf("expected a valid cursor, got %v", err)
+		}
+		return c
+	}
+
+	t.Run("first page", func(t *testing.T) {
+		movies, metadata := data.CursorPage(newMovies(), data.Cursor{Sort: "-year"}, filters, 3)
+
+		if len(movies) != 2 || movies[0].ID != 3 || movies[1].ID != 2 {
+			t.Fatalf("expected movies 3 and 2, got %v", movies)
+		}
+		if metadata.PrevCursor != "" {
+			t.Errorf("expected no previous cursor on the first page, got %q", metadata.PrevCursor)
+		}
+
+		expected := data.Cursor{Sort: "-year", Values: []any{json.Number("2017"), json.Number("2")}}
+		if diff := cmp.Diff(expected, decode(t, metadata.NextCursor)); diff != "" {
+			t.Errorf("next cursor mismatch (-expected +got):\n%s", diff)
+		}
+	})
+
+	t.Run("backward page", func(t *testing.T) {
+		f := filters
+		f.Cursor = "from-a-later-page"
+
+		// Backward pages are fetched in reverse order.
+		movies := []*data.Movie{
+			{ID: 1, Title: "Moana", Year: 2016},
+			{ID: 2, Title: "Coco", Year: 2017},
+		}
+		movies, metadata := data.CursorPage(movies, data.Cursor{Sort: "-year", Backward: true}, f, 3)
+
+		if len(movies) != 2 || movies[0].ID != 2 || movies[1].ID != 1 {
+			t.Fatalf("expected movies 2 and 1, got %v", movies)
+		}
+		if metadata.PrevCursor != "" {
+			t.Errorf("expected no previous cursor, got %q", metadata.PrevCursor)
+		}
+
+		expected := data.Cursor{Sort: "-year", Values: []any{json.Number("2016"), json.Number("1")}}
+		if diff := cmp.Diff(expected, decode(t, metadata.NextCursor)); diff != "" {
+			t.Errorf("next cursor mismatch (-expected +got):\n%s", diff)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	ImageKindPoster   = "poster"
+	ImageKindBackdrop = "backdrop"
+)
+
+var ImageKinds = []string{ImageKindPoster, ImageKindBackdrop}
+
+// MovieImage is an uploaded image of a movie and its thumbnail. The storage
+// keys are only used to delete the files, and are not part of the JSON.
+type MovieImage struct {
+	Kind         string `json:"kind"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int64  `json:"size"`
+	Key          string `json:"-"`
+	ThumbnailKey string `json:"-"`
+}
+
+// MovieImages is selected along with the movie columns as a JSON array,
+// built by movieImagesColumn.
+type MovieImages []MovieImage
+
+func (i *MovieImages) Scan(src any) error {
+	return scanJSON(src, i)
+}
+
+// movieImagesColumn selects the images of each movie of a query on the
+// movies table.
+const movieImagesColumn = `COALESCE((
+	SELECT jsonb_agg(jsonb_build_object(
+		'kind', kind, 'url', url, 'thumbnail_url', thumbnail_url,
+		'content_type', content_type, 'width', width, 'height', height, 'size', size
+	) ORDER BY kind)
+	FROM movie_images WHERE movie_id = movies.id
+), '[]') AS images`
+
+type MovieImageModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// Upsert saves the image of the movie, replacing the one of the same kind if
+// any. It returns the storage keys of the replaced image, which are no longer
+// referenced.
+func (m MovieImageModel) Upsert(ctx context.Context, movieID int64, image *MovieImage) ([]string, error) {
+	query := `
+	WITH replaced AS (
+		SELECT key, thumbnail_key FROM movie_images WHERE movie_id = $1 AND kind = $2
+	)
+	INSERT INTO movie_images
+		(movie_id, kind, key, url, thumbnail_key, thumbnail_url, content_type, width, height, size)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (movie_id, kind) DO UPDATE SET
+		key = EXCLUDED.key,
+		url = EXCLUDED.url,
+		thumbnail_key = EXCLUDED.thumbnail_key,
+		thumbnail_url = EXCLUDED.thumbnail_url,
+		content_type = EXCLUDED.content_type,
+		width = EXCLUDED.width,
+		height = EXCLUDED.height,
+		size = EXCLUDED.size,
+		created_at = NOW()
+	RETURNING (SELECT key FROM replaced), (SELECT thumbnail_key FROM replaced)`
+
+	args := []any{
+		movieID,
+		image.Kind,
+		image.Key,
+		image.URL,
+		image.ThumbnailKey,
+		image.ThumbnailURL,
+		image.ContentType,
+		image.Width,
+		image.Height,
+		image.Size,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var key, thumbnailKey sql.NullString
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&key, &thumbnailKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.Valid {
+		return nil, nil
+	}
+
+	return []string{key.String, thumbnailKey.String}, nil
+}
+
+// Delete removes the image of the given kind from the movie, returning its
+// storage keys.
+func (m MovieImageModel) Delete(ctx context.Context, movieID int64, kind string) ([]string, error) {
+	query := `
+	DELETE FROM movie_images WHERE movie_id = $1 AND kind = $2
+	RETURNING key, thumbnail_key`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var key, thumbnailKey string
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, kind).Scan(&key, &thumbnailKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return []string{key, thumbnailKey}, nil
+}
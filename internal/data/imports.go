@@ -0,0 +1,143 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	ImportStatusPending   = "pending"
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+	ImportStatusFailed    = "failed"
+)
+
+// maxImportRowErrors caps the row errors kept in a report, so that a file of
+// garbage does not produce an equally large report.
+const maxImportRowErrors = 1000
+
+type ImportRowError struct {
+	Line   int               `json:"line"`
+	Errors map[string]string `json:"errors"`
+}
+
+type ImportReport struct {
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+func (r *ImportReport) AddError(line int, errors map[string]string) {
+	r.Failed++
+	if len(r.Errors) < maxImportRowErrors {
+		r.Errors = append(r.Errors, ImportRowError{Line: line, Errors: errors})
+	}
+}
+
+// ImportJob tracks a movie import running in the background.
+type ImportJob struct {
+	ID        int64        `json:"id"`
+	UserID    int64        `json:"-"`
+	Format    string       `json:"format"`
+	Status    string       `json:"status"`
+	Report    ImportReport `json:"report"`
+	Error     string       `json:"error,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type ImportJobModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+func (m ImportJobModel) Insert(ctx context.Context, job *ImportJob) error {
+	query := `
+	INSERT INTO import_jobs (user_id, format, status)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.
+		QueryRowContext(ctx, query, job.UserID, job.Format, job.Status).
+		Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+}
+
+func (m ImportJobModel) Update(ctx context.Context, job *ImportJob) error {
+	query := `
+	UPDATE import_jobs SET status = $1, report = $2, error = $3, updated_at = NOW()
+	WHERE id = $4
+	RETURNING updated_at`
+
+	report, err := json.Marshal(job.Report)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err = m.DB.
+		QueryRowContext(ctx, query, job.Status, report, job.Error, job.ID).
+		Scan(&job.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ImportJobModel) Get(ctx context.Context, id int64) (*ImportJob, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, user_id, format, status, report, error, created_at, updated_at
+	FROM import_jobs
+	WHERE id = $1`
+
+	var (
+		job    ImportJob
+		report []byte
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Format,
+		&job.Status,
+		&report,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if err = json.Unmarshal(report, &job.Report); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
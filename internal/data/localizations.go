@@ -0,0 +1,219 @@
+package data
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+var (
+	// LocaleRX matches lowercase BCP 47 language tags such as "fr" or "pt-br".
+	LocaleRX = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+	// CountryRX matches ISO 3166-1 alpha-2 country codes.
+	CountryRX = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
+// MovieTitle is the title a movie is known by in a locale.
+type MovieTitle struct {
+	Locale string `json:"locale"`
+	Title  string `json:"title"`
+}
+
+type MovieTitles []MovieTitle
+
+func (t *MovieTitles) Scan(src any) error {
+	return scanJSON(src, t)
+}
+
+// MovieRelease is the release of a movie in a country. ReleaseDate is
+// formatted as YYYY-MM-DD.
+type MovieRelease struct {
+	Country       string `json:"country"`
+	ReleaseDate   string `json:"release_date"`
+	Certification string `json:"certification"`
+}
+
+type MovieReleases []MovieRelease
+
+func (r *MovieReleases) Scan(src any) error {
+	return scanJSON(src, r)
+}
+
+// movieTitlesColumn and movieReleasesColumn select the alternate titles and
+// releases of each movie of a query on the movies table.
+const (
+	movieTitlesColumn = `COALESCE((
+	SELECT jsonb_agg(jsonb_build_object('locale', locale, 'title', title) ORDER BY locale)
+	FROM movie_titles WHERE movie_id = movies.id
+), '[]') AS titles`
+
+	movieReleasesColumn = `COALESCE((
+	SELECT jsonb_agg(jsonb_build_object(
+		'country', country, 'release_date', release_date, 'certification', certification
+	) ORDER BY country)
+	FROM movie_releases WHERE movie_id = movies.id
+), '[]') AS releases`
+)
+
+// localizedTitleColumn selects the alternate title of the first of the
+// locales that a movie has one for, or an empty string.
+func localizedTitleColumn(locales []string, args *queryArgs) string {
+	if len(locales) == 0 {
+		return "'' AS localized_title"
+	}
+
+	placeholder := args.add(pq.Array(locales)) + "::text[]"
+
+	return `COALESCE((
+	SELECT t.title FROM movie_titles t
+	WHERE t.movie_id = movies.id AND t.locale = ANY(` + placeholder + `)
+	ORDER BY array_position(` + placeholder + `, t.locale)
+	LIMIT 1
+), '') AS localized_title`
+}
+
+// Localize replaces the title of the movie by its alternate title in the
+// first of the locales it has one for, keeping the original title in
+// OriginalTitle. It returns the chosen locale, or an empty string when the
+// original title is kept.
+func (m *Movie) Localize(locales []string) string {
+	for _, locale := range locales {
+		for _, t := range m.Titles {
+			if t.Locale == locale {
+				m.setLocalizedTitle(t.Title)
+				return locale
+			}
+		}
+	}
+
+	return ""
+}
+
+func (m *Movie) setLocalizedTitle(title string) {
+	if title == "" || title == m.Title {
+		return
+	}
+
+	m.OriginalTitle = m.Title
+	m.Title = title
+}
+
+// originalTitle returns the title the movie is stored with, whether it was
+// localized or not.
+func (m *Movie) originalTitle() string {
+	if m.OriginalTitle != "" {
+		return m.OriginalTitle
+	}
+
+	return m.Title
+}
+
+func ValidateMovieTitle(v *validator.Validator, t *MovieTitle) {
+	v.Check(LocaleRX.MatchString(t.Locale), "locale", "must be a valid language tag")
+
+	v.Check(t.Title != "", "title", "must be provided")
+	v.Check(len(t.Title) <= 500, "title", "must not be longer than 500 bytes")
+}
+
+func ValidateMovieRelease(v *validator.Validator, r *MovieRelease) {
+	v.Check(CountryRX.MatchString(r.Country), "country", "must be an ISO 3166-1 alpha-2 code")
+
+	v.Check(r.ReleaseDate != "", "release_date", "must be provided")
+	if r.ReleaseDate != "" {
+		date, err := time.Parse(time.DateOnly, r.ReleaseDate)
+		v.Check(err == nil, "release_date", "must be a date formatted as YYYY-MM-DD")
+		v.Check(err != nil || date.Year() >= 1888, "release_date", "must not be before 1888")
+	}
+
+	v.Check(len(r.Certification) <= 20, "certification", "must not be longer than 20 bytes")
+}
+
+// NormalizeLocale lowercases a language tag and uses hyphens as separators,
+// as locales are stored.
+func NormalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+}
+
+type MovieTitleModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// Upsert sets the title of the movie in the locale.
+func (m MovieTitleModel) Upsert(ctx context.Context, movieID int64, t *MovieTitle) error {
+	query := `
+	INSERT INTO movie_titles (movie_id, locale, title)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (movie_id, locale) DO UPDATE SET title = EXCLUDED.title`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, movieID, t.Locale, t.Title)
+	return err
+}
+
+func (m MovieTitleModel) Delete(ctx context.Context, movieID int64, locale string) error {
+	query := `DELETE FROM movie_titles WHERE movie_id = $1 AND locale = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return execOne(ctx, m.DB, query, movieID, locale)
+}
+
+type MovieReleaseModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// Upsert sets the release of the movie in the country.
+func (m MovieReleaseModel) Upsert(ctx context.Context, movieID int64, r *MovieRelease) error {
+	query := `
+	INSERT INTO movie_releases (movie_id, country, release_date, certification)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (movie_id, country) DO UPDATE SET
+		release_date = EXCLUDED.release_date,
+		certification = EXCLUDED.certification`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, movieID, r.Country, r.ReleaseDate, r.Certification)
+	return err
+}
+
+func (m MovieReleaseModel) Delete(ctx context.Context, movieID int64, country string) error {
+	query := `DELETE FROM movie_releases WHERE movie_id = $1 AND country = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return execOne(ctx, m.DB, query, movieID, country)
+}
+
+// execOne runs a statement expected to affect a single row, returning
+// ErrRecordNotFound when it affects none.
+func execOne(ctx context.Context, db DBTX, query string, args ...any) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
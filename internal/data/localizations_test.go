@@ -0,0 +1,103 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func TestLocalize(t *testing.T) {
+	titles := data.MovieTitles{
+		{Locale: "fr", Title: "Vaiana"},
+		{Locale: "pt-br", Title: "Moana: Um Mar de Aventuras"},
+	}
+
+	tests := []struct {
+		desc          string
+		locales       []string
+		locale        string
+		title         string
+		originalTitle string
+	}{
+		{
+			desc:    "original title without locales",
+			locales: nil,
+			title:   "Moana",
+		},
+		{
+			desc:    "original title without a matching locale",
+			locales: []string{"de", "it"},
+			title:   "Moana",
+		},
+		{
+			desc:          "first matching locale wins",
+			locales:       []string{"de", "pt-br", "fr"},
+			locale:        "pt-br",
+			title:         "Moana: Um Mar de Aventuras",
+			originalTitle: "Moana",
+		},
+		{
+			desc:          "general locale after the specific one",
+			locales:       []string{"fr-ca", "fr"},
+			locale:        "fr",
+			title:         "Vaiana",
+			originalTitle: "Moana",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			movie := &data.Movie{Title: "Moana", Titles: titles}
+
+			locale := movie.Localize(tt.locales)
+
+			got := []string{locale, movie.Title, movie.OriginalTitle}
+			expected := []string{tt.locale, tt.title, tt.originalTitle}
+
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Errorf("localization does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidateMovieRelease(t *testing.T) {
+	tests := []struct {
+		desc     string
+		release  data.MovieRelease
+		expected map[string]string
+	}{
+		{
+			desc:     "valid release",
+			release:  data.MovieRelease{Country: "FR", ReleaseDate: "2016-11-30", Certification: "U"},
+			expected: map[string]string{},
+		},
+		{
+			desc:    "invalid country and date",
+			release: data.MovieRelease{Country: "fra", ReleaseDate: "30/11/2016"},
+			expected: map[string]string{
+				"country":      "must be an ISO 3166-1 alpha-2 code",
+				"release_date": "must be a date formatted as YYYY-MM-DD",
+			},
+		},
+		{
+			desc:     "date before cinema",
+			release:  data.MovieRelease{Country: "US", ReleaseDate: "1850-01-01"},
+			expected: map[string]string{"release_date": "must not be before 1888"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateMovieRelease(v, &tt.release)
+
+			if diff := cmp.Diff(tt.expected, v.Errors); diff != "" {
+				t.Errorf("errors does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
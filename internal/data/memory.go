@@ -0,0 +1,302 @@
+package data
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// errMissingReference is returned by the memory models where PostgreSQL
+// would report a foreign key violation.
+var errMissingReference = errors.New("memory: referenced record does not exist")
+
+// NewMemoryModels returns models keeping their data in memory, for tests
+// that exercise the handlers without PostgreSQL. They honor the same errors,
+// version checks and transactions as the database models, and approximate
+// full-text and trigram search with case-insensitive word matching, without
+// stemming or typo tolerance.
+func NewMemoryModels() Models {
+	return newMemoryModels(memoryStore{db: &memoryDB{tables: newMemoryTables()}})
+}
+
+func newMemoryModels(s memoryStore) Models {
+	return Models{
+		Movies:         memoryMovieModel{s},
+		MovieRevisions: memoryMovieRevisionModel{s},
+		MovieImages:    memoryMovieImageModel{s},
+		MovieTitles:    memoryMovieTitleModel{s},
+		MovieReleases:  memoryMovieReleaseModel{s},
+		ExternalIDs:    memoryMovieExternalIDModel{s},
+		MovieChanges:   memoryMovieChangeModel{s},
+		MovieRatings:   memoryMovieRatingModel{s},
+		Tags:           memoryTagModel{s},
+		Users:          memoryUserModel{s},
+		Tokens:         memoryTokenModel{s},
+		Permissions:    memoryPermissionModel{s},
+		ImportJobs:     memoryImportJobModel{s},
+		withTx: func(ctx context.Context, fn func(tx Models) error) error {
+			return s.withTx(ctx, func(tx memoryStore) error {
+				return fn(newMemoryModels(tx))
+			})
+		},
+	}
+}
+
+// memoryDB holds the tables of the memory models. Writes and transactions
+// work on a copy of the tables that replaces them once they succeed, so that
+// readers never see partial changes and failures leave no trace.
+type memoryDB struct {
+	// writeMu serializes writes and transactions, so that none is lost,
+	// while mu only guards the replacement of the tables.
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	tables  *memoryTables
+}
+
+// memoryStore gives the memory models access to the tables, either directly
+// or through the transaction they are part of. Like a database connection
+// while a transaction holds row locks, models outside of a transaction must
+// not be used to write from within it.
+type memoryStore struct {
+	db *memoryDB
+	tx *memoryTables
+}
+
+// view runs fn on the current tables, which it must not modify.
+func (s memoryStore) view(ctx context.Context, fn func(t *memoryTables) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+
+	return fn(s.db.tables)
+}
+
+// update runs fn on a copy of the tables, which replaces them when fn
+// succeeds, so that every write is atomic.
+func (s memoryStore) update(ctx context.Context, fn func(t *memoryTables) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if s.tx != nil {
+		t := s.tx.clone()
+		if err := fn(t); err != nil {
+			return err
+		}
+		*s.tx = *t
+		return nil
+	}
+
+	s.db.writeMu.Lock()
+	defer s.db.writeMu.Unlock()
+
+	t := s.db.tables.clone()
+	if err := fn(t); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	s.db.tables = t
+	s.db.mu.Unlock()
+
+	return nil
+}
+
+// withTx runs fn with a store whose changes are only applied when fn
+// succeeds. Called on the store of a transaction, fn simply becomes part of
+// it.
+func (s memoryStore) withTx(ctx context.Context, fn func(tx memoryStore) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.writeMu.Lock()
+	defer s.db.writeMu.Unlock()
+
+	tx := s.db.tables.clone()
+	if err := fn(memoryStore{db: s.db, tx: tx}); err != nil {
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	s.db.tables = tx
+	s.db.mu.Unlock()
+
+	return nil
+}
+
+// memoryTables mirrors the tables of the database. Rows are stored by value
+// and their slices are replaced rather than modified, so that copying the
+// maps is enough to copy the tables.
+type memoryTables struct {
+	movies      map[int64]Movie
+	revisions   map[movieVersionKey]MovieRevision
+	images      map[movieChildKey]MovieImage
+	titles      map[movieChildKey]MovieTitle
+	releases    map[movieChildKey]MovieRelease
+	externalIDs map[ExternalID]int64
+	changes     map[int64]MovieChange
+	ratings     map[userMovieKey]MovieRating
+	tags        map[int64]Tag
+	movieTags   map[movieTagKey]struct{}
+	users       map[int64]User
+	tokens      map[string]Token
+	permissions map[userPermissionKey]struct{}
+	importJobs  map[int64]ImportJob
+
+	lastIDs struct {
+		movies, changes, tags, users, importJobs int64
+	}
+}
+
+// movieChildKey identifies a child of a movie by the column that is unique
+// per movie, such as the kind of an image or the locale of a title.
+type movieChildKey struct {
+	movieID int64
+	key     string
+}
+
+type movieVersionKey struct {
+	movieID int64
+	version int32
+}
+
+type userMovieKey struct {
+	userID  int64
+	movieID int64
+}
+
+type movieTagKey struct {
+	movieID int64
+	tagID   int64
+}
+
+type userPermissionKey struct {
+	userID int64
+	code   string
+}
+
+func newMemoryTables() *memoryTables {
+	return &memoryTables{
+		movies:      make(map[int64]Movie),
+		revisions:   make(map[movieVersionKey]MovieRevision),
+		images:      make(map[movieChildKey]MovieImage),
+		titles:      make(map[movieChildKey]MovieTitle),
+		releases:    make(map[movieChildKey]MovieRelease),
+		externalIDs: make(map[ExternalID]int64),
+		changes:     make(map[int64]MovieChange),
+		ratings:     make(map[userMovieKey]MovieRating),
+		tags:        make(map[int64]Tag),
+		movieTags:   make(map[movieTagKey]struct{}),
+		users:       make(map[int64]User),
+		tokens:      make(map[string]Token),
+		permissions: make(map[userPermissionKey]struct{}),
+		importJobs:  make(map[int64]ImportJob),
+	}
+}
+
+func (t *memoryTables) clone() *memoryTables {
+	return &memoryTables{
+		movies:      maps.Clone(t.movies),
+		revisions:   maps.Clone(t.revisions),
+		images:      maps.Clone(t.images),
+		titles:      maps.Clone(t.titles),
+		releases:    maps.Clone(t.releases),
+		externalIDs: maps.Clone(t.externalIDs),
+		changes:     maps.Clone(t.changes),
+		ratings:     maps.Clone(t.ratings),
+		tags:        maps.Clone(t.tags),
+		movieTags:   maps.Clone(t.movieTags),
+		users:       maps.Clone(t.users),
+		tokens:      maps.Clone(t.tokens),
+		permissions: maps.Clone(t.permissions),
+		importJobs:  maps.Clone(t.importJobs),
+		lastIDs:     t.lastIDs,
+	}
+}
+
+// memoryNow returns the current time at the precision of the timestamp
+// columns.
+func memoryNow() time.Time {
+	return time.Now().Truncate(time.Second)
+}
+
+// sortRows orders the rows as the ORDER BY clause of the sort keys would,
+// value giving the value of a sort column for a row. Every direction is
+// reversed when backward is true, as when paging backward from a cursor.
+func sortRows[T any](rows []T, keys []sortKey, backward bool, value func(row T, column string) any) {
+	slices.SortStableFunc(rows, func(a, b T) int {
+		return compareSortValues(keys, backward, sortValues(a, keys, value), sortValues(b, keys, value))
+	})
+}
+
+func sortValues[T any](row T, keys []sortKey, value func(row T, column string) any) []any {
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		values[i] = value(row, k.column)
+	}
+	return values
+}
+
+// compareSortValues compares the values of the sort keys of two rows, the
+// first differing key deciding of their order.
+func compareSortValues(keys []sortKey, backward bool, a, b []any) int {
+	for i, k := range keys {
+		c := compareValues(a[i], b[i])
+		if k.desc != backward {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareValues(a, b any) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case int32:
+		return cmp.Compare(a, b.(int32))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case float32:
+		return cmp.Compare(a, b.(float32))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	default:
+		panic(fmt.Sprintf("cannot compare values of type %T", a))
+	}
+}
+
+// pageRows returns the page of the sorted rows selected by the filters, as
+// LIMIT and OFFSET would, along with its metadata.
+func pageRows[T any](rows []T, filters Filters) ([]T, Metadata) {
+	start := min(filters.offset(), len(rows))
+	end := min(start+filters.limit(), len(rows))
+
+	return rows[start:end], newMetadata(len(rows), filters.Page, filters.PageSize)
+}
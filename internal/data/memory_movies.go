@@ -0,0 +1,1351 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// row returns the columns of the movie that are stored in the movies table.
+func (m *Movie) row() Movie {
+	return Movie{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Title:     m.Title,
+		Year:      m.Year,
+		Runtime:   m.Runtime,
+		Genres:    slices.Clone(m.Genres),
+		Version:   m.Version,
+		Status:    m.Status,
+		DeletedAt: m.DeletedAt,
+	}
+}
+
+// movie returns the movie with all of its children, as selected by
+// movieColumns, when it is not deleted.
+func (t *memoryTables) movie(id int64) (*Movie, error) {
+	row, ok := t.movies[id]
+	if !ok || row.DeletedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+
+	return t.loadMovie(row), nil
+}
+
+func (t *memoryTables) loadMovie(row Movie) *Movie {
+	movie := row.row()
+	movie.Images = t.movieImages(row.ID)
+	movie.Titles = t.movieTitles(row.ID)
+	movie.Releases = t.movieReleases(row.ID)
+	movie.ExternalIDs = t.movieExternalIDs(row.ID)
+	movie.Tags = t.movieTagNames(row.ID)
+
+	return &movie
+}
+
+func (t *memoryTables) movieImages(movieID int64) MovieImages {
+	images := MovieImages{}
+	for key, image := range t.images {
+		if key.movieID == movieID {
+			image.Key, image.ThumbnailKey = "", ""
+			images = append(images, image)
+		}
+	}
+
+	slices.SortFunc(images, func(a, b MovieImage) int { return strings.Compare(a.Kind, b.Kind) })
+	return images
+}
+
+// movieImageKeys returns the storage keys of the images of the movie.
+func (t *memoryTables) movieImageKeys(movieID int64) []string {
+	var images []MovieImage
+	for key, image := range t.images {
+		if key.movieID == movieID {
+			images = append(images, image)
+		}
+	}
+
+	slices.SortFunc(images, func(a, b MovieImage) int { return strings.Compare(a.Kind, b.Kind) })
+
+	keys := []string{}
+	for _, image := range images {
+		keys = append(keys, image.Key, image.ThumbnailKey)
+	}
+	return keys
+}
+
+func (t *memoryTables) movieTitles(movieID int64) MovieTitles {
+	titles := MovieTitles{}
+	for key, title := range t.titles {
+		if key.movieID == movieID {
+			titles = append(titles, title)
+		}
+	}
+
+	slices.SortFunc(titles, func(a, b MovieTitle) int { return strings.Compare(a.Locale, b.Locale) })
+	return titles
+}
+
+func (t *memoryTables) movieReleases(movieID int64) MovieReleases {
+	releases := MovieReleases{}
+	for key, release := range t.releases {
+		if key.movieID == movieID {
+			releases = append(releases, release)
+		}
+	}
+
+	slices.SortFunc(releases, func(a, b MovieRelease) int { return strings.Compare(a.Country, b.Country) })
+	return releases
+}
+
+func (t *memoryTables) movieExternalIDs(movieID int64) ExternalIDs {
+	externalIDs := ExternalIDs{}
+	for externalID, id := range t.externalIDs {
+		if id == movieID {
+			externalIDs = append(externalIDs, externalID)
+		}
+	}
+
+	slices.SortFunc(externalIDs, func(a, b ExternalID) int {
+		return strings.Compare(a.Source+" "+a.ID, b.Source+" "+b.ID)
+	})
+	return externalIDs
+}
+
+// movieTagNames returns the names of the approved tags of the movie.
+func (t *memoryTables) movieTagNames(movieID int64) MovieTags {
+	names := MovieTags{}
+	for key := range t.movieTags {
+		if tag := t.tags[key.tagID]; key.movieID == movieID && tag.Status == TagStatusApproved {
+			names = append(names, tag.Name)
+		}
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+// localizedTitle returns the alternate title of the first of the locales
+// that the movie has one for, or an empty string.
+func (t *memoryTables) localizedTitle(movieID int64, locales []string) string {
+	for _, locale := range locales {
+		if title, ok := t.titles[movieChildKey{movieID, locale}]; ok {
+			return title.Title
+		}
+	}
+	return ""
+}
+
+func (t *memoryTables) insertMovie(movie *Movie, userID int64) error {
+	t.lastIDs.movies++
+
+	movie.ID = t.lastIDs.movies
+	movie.CreatedAt = memoryNow()
+	movie.Version = 1
+	t.movies[movie.ID] = movie.row()
+
+	for _, externalID := range movie.ExternalIDs {
+		if err := t.insertExternalID(movie.ID, externalID); err != nil {
+			return err
+		}
+	}
+
+	t.insertMovieRevision(movie, userID)
+	return nil
+}
+
+func (t *memoryTables) updateMovie(movie *Movie, userID int64) error {
+	row, ok := t.movies[movie.ID]
+	if !ok || row.Version != movie.Version || row.DeletedAt != nil {
+		return ErrEditConflict
+	}
+
+	row.Title = movie.Title
+	row.Year = movie.Year
+	row.Runtime = movie.Runtime
+	row.Genres = slices.Clone(movie.Genres)
+	row.Version++
+	t.movies[movie.ID] = row
+
+	movie.Version = row.Version
+	t.insertMovieRevision(movie, userID)
+	return nil
+}
+
+// setMovieStatus changes the status of the movie when it is still at the
+// same version and in one of the given statuses, if any.
+func (t *memoryTables) setMovieStatus(movie *Movie, status string, from ...string) error {
+	row, ok := t.movies[movie.ID]
+	if !ok || row.Version != movie.Version || row.DeletedAt != nil {
+		return ErrEditConflict
+	}
+	if len(from) > 0 && !slices.Contains(from, row.Status) {
+		return ErrEditConflict
+	}
+
+	row.Status = status
+	t.movies[movie.ID] = row
+
+	movie.Status = status
+	return nil
+}
+
+func (t *memoryTables) insertMovieRevision(movie *Movie, userID int64) {
+	t.revisions[movieVersionKey{movie.ID, movie.Version}] = MovieRevision{
+		MovieID:   movie.ID,
+		Version:   movie.Version,
+		Title:     movie.Title,
+		Year:      movie.Year,
+		Runtime:   movie.Runtime,
+		Genres:    slices.Clone(movie.Genres),
+		UserID:    userID,
+		CreatedAt: memoryNow(),
+	}
+}
+
+func (t *memoryTables) insertExternalID(movieID int64, e ExternalID) error {
+	if _, ok := t.movies[movieID]; !ok {
+		return errMissingReference
+	}
+
+	if _, ok := t.externalIDs[e]; ok {
+		return ErrDuplicateExternalID
+	}
+
+	t.externalIDs[e] = movieID
+	return nil
+}
+
+// purgeMovie permanently removes the movie along with its children.
+func (t *memoryTables) purgeMovie(id int64) {
+	delete(t.movies, id)
+
+	for key := range t.revisions {
+		if key.movieID == id {
+			delete(t.revisions, key)
+		}
+	}
+	for key := range t.images {
+		if key.movieID == id {
+			delete(t.images, key)
+		}
+	}
+	for key := range t.titles {
+		if key.movieID == id {
+			delete(t.titles, key)
+		}
+	}
+	for key := range t.releases {
+		if key.movieID == id {
+			delete(t.releases, key)
+		}
+	}
+	for externalID, movieID := range t.externalIDs {
+		if movieID == id {
+			delete(t.externalIDs, externalID)
+		}
+	}
+	for changeID, change := range t.changes {
+		if change.MovieID == id {
+			delete(t.changes, changeID)
+		}
+	}
+	for key := range t.ratings {
+		if key.movieID == id {
+			delete(t.ratings, key)
+		}
+	}
+	for key := range t.movieTags {
+		if key.movieID == id {
+			delete(t.movieTags, key)
+		}
+	}
+}
+
+// inScope reports whether the movie is not deleted and has one of the
+// statuses of q, as MovieQuery.scope.
+func inScope(row Movie, q MovieQuery) bool {
+	return row.DeletedAt == nil && (len(q.Statuses) == 0 || slices.Contains(q.Statuses, row.Status))
+}
+
+// matchingRows returns the movies matching q, as MovieQuery.where, in id
+// order.
+func (t *memoryTables) matchingRows(q MovieQuery) []Movie {
+	rows := []Movie{}
+	for _, row := range t.movies {
+		if !inScope(row, q) || !t.hasTags(row.ID, q.Tags, q.TagMatch) {
+			continue
+		}
+
+		if !containsAll(row.Genres, q.Genres) {
+			continue
+		}
+
+		if _, ok := t.searchMovie(row, q); !ok {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	slices.SortFunc(rows, func(a, b Movie) int { return compareValues(a.ID, b.ID) })
+	return rows
+}
+
+// hasTags reports whether the movie has approved tags matching all of the
+// names, or any of them with TagMatchAny.
+func (t *memoryTables) hasTags(movieID int64, names []string, match string) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	tags := t.movieTagNames(movieID)
+	if match == TagMatchAny {
+		return slices.ContainsFunc(names, func(name string) bool { return slices.Contains(tags, name) })
+	}
+	return containsAll(tags, names)
+}
+
+func containsAll(values, wanted []string) bool {
+	for _, v := range wanted {
+		if !slices.Contains(values, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchMovie reports whether the title or an alternate title of the movie
+// matches the title and search of q, returning its relevance.
+func (t *memoryTables) searchMovie(row Movie, q MovieQuery) (float32, bool) {
+	if q.Fuzzy {
+		text := strings.ToLower(q.fuzzyText())
+		title := strings.ToLower(row.Title)
+		if !strings.Contains(title, text) {
+			return 0, false
+		}
+		return float32(len(text)) / float32(max(len(title), 1)), true
+	}
+
+	titles := []string{row.Title}
+	for _, title := range t.movieTitles(row.ID) {
+		titles = append(titles, title.Title)
+	}
+
+	matchesAny := func(s memorySearch) bool {
+		return slices.ContainsFunc(titles, func(title string) bool { return s.matches(searchWords(title)) })
+	}
+
+	if q.Title != "" && !matchesAny(parseTitleSearch(q.Title)) {
+		return 0, false
+	}
+
+	if q.Search == "" {
+		return 0, true
+	}
+
+	search := parseWebSearch(q.Search)
+	if !matchesAny(search) {
+		return 0, false
+	}
+
+	return search.rank(searchWords(row.Title)), true
+}
+
+// listedMovie returns the movie as selected by the listing queries of q,
+// with the children selected by its projection.
+func (t *memoryTables) listedMovie(row Movie, q MovieQuery) *Movie {
+	movie := row.row()
+	p := q.Projection
+
+	if p.HasField("images") {
+		movie.Images = t.movieImages(row.ID)
+	}
+	if p.HasField("title") || p.HasField("original_title") {
+		movie.setLocalizedTitle(t.localizedTitle(row.ID, q.Locales))
+	}
+	if p.Includes("titles") {
+		movie.Titles = t.movieTitles(row.ID)
+	}
+	if p.Includes("releases") {
+		movie.Releases = t.movieReleases(row.ID)
+	}
+	if p.Includes("external_ids") {
+		movie.ExternalIDs = t.movieExternalIDs(row.ID)
+	}
+	if p.Includes("tags") {
+		movie.Tags = t.movieTagNames(row.ID)
+	}
+
+	return &movie
+}
+
+// listedMovies returns the movies matching q as selected by its listing
+// queries, along with their search headline and relevance.
+func (t *memoryTables) listedMovies(q MovieQuery) []*Movie {
+	movies := []*Movie{}
+	for _, row := range t.matchingRows(q) {
+		movie := t.listedMovie(row, q)
+		movie.Relevance, _ = t.searchMovie(row, q)
+
+		if q.Search != "" && !q.Fuzzy && q.Projection.HasField("headline") {
+			movie.Headline = parseWebSearch(q.Search).headline(row.Title)
+		}
+
+		movies = append(movies, movie)
+	}
+	return movies
+}
+
+// movieSortValue returns the value of the sort column of the movie, as
+// stored in the movies table.
+func movieSortValue(m *Movie, column string) any {
+	switch column {
+	case "id":
+		return m.ID
+	case "title":
+		return m.originalTitle()
+	case "year":
+		return m.Year
+	case "runtime":
+		return int32(m.Runtime)
+	case "relevance":
+		return m.Relevance
+	case "deleted_at":
+		if m.DeletedAt == nil {
+			return time.Time{}
+		}
+		return *m.DeletedAt
+	default:
+		panic("unsupported sort column " + column)
+	}
+}
+
+// cursorSortValues returns the values of the cursor with the types of the
+// movie sort columns, numbers being decoded as json.Number.
+func cursorSortValues(c cursor, keys []sortKey) []any {
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		n, ok := c.Values[i].(json.Number)
+		if !ok {
+			values[i] = c.Values[i]
+			continue
+		}
+
+		v, _ := n.Int64()
+		if k.column == "id" {
+			values[i] = v
+		} else {
+			values[i] = int32(v)
+		}
+	}
+	return values
+}
+
+// memorySearch approximates a text search query without stemming: the
+// title words must match a word of every group, where the words are
+// alternatives, and none of the negated words.
+type memorySearch struct {
+	groups  [][]searchWord
+	negated []string
+}
+
+type searchWord struct {
+	text   string
+	prefix bool
+}
+
+// parseTitleSearch parses a title filter, which matches every word, as
+// plainto_tsquery does.
+func parseTitleSearch(title string) memorySearch {
+	var s memorySearch
+	for _, word := range searchWords(title) {
+		s.groups = append(s.groups, []searchWord{{text: word}})
+	}
+	return s
+}
+
+// parseWebSearch parses a search in web search syntax, as MovieQuery.tsquery
+// does, phrases being matched as separate words.
+func parseWebSearch(search string) memorySearch {
+	var s memorySearch
+
+	rest, prefix := splitPrefixTerm(search)
+	alternative := false
+
+	for _, field := range strings.Fields(strings.ReplaceAll(rest, `"`, " ")) {
+		if strings.EqualFold(field, "or") {
+			alternative = len(s.groups) > 0
+			continue
+		}
+
+		if negated, ok := strings.CutPrefix(field, "-"); ok {
+			s.negated = append(s.negated, searchWords(negated)...)
+			alternative = false
+			continue
+		}
+
+		for i, word := range searchWords(field) {
+			s.add(searchWord{text: word}, alternative && i == 0)
+		}
+		alternative = false
+	}
+
+	if prefix != "" {
+		s.add(searchWord{text: strings.ToLower(prefix), prefix: true}, false)
+	}
+
+	return s
+}
+
+func (s *memorySearch) add(word searchWord, alternative bool) {
+	if alternative {
+		last := len(s.groups) - 1
+		s.groups[last] = append(s.groups[last], word)
+		return
+	}
+
+	s.groups = append(s.groups, []searchWord{word})
+}
+
+func (s memorySearch) matches(words []string) bool {
+	for _, word := range s.negated {
+		if slices.Contains(words, word) {
+			return false
+		}
+	}
+
+	for _, group := range s.groups {
+		if !slices.ContainsFunc(group, func(w searchWord) bool { return w.matchesAny(words) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// rank returns the share of the words that match the search.
+func (s memorySearch) rank(words []string) float32 {
+	matched := 0
+	for _, word := range words {
+		if s.highlights(word) {
+			matched++
+		}
+	}
+
+	return float32(matched) / float32(max(len(words), 1))
+}
+
+func (s memorySearch) highlights(word string) bool {
+	for _, group := range s.groups {
+		if slices.ContainsFunc(group, func(w searchWord) bool { return w.matchesAny([]string{word}) }) {
+			return true
+		}
+	}
+	return false
+}
+
+var searchWordRX = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// headline marks the words of the title that match the search, as
+// ts_headline does.
+func (s memorySearch) headline(title string) string {
+	return searchWordRX.ReplaceAllStringFunc(title, func(word string) string {
+		if s.highlights(strings.ToLower(word)) {
+			return "<mark>" + word + "</mark>"
+		}
+		return word
+	})
+}
+
+func (w searchWord) matchesAny(words []string) bool {
+	return slices.ContainsFunc(words, func(word string) bool {
+		return word == w.text || (w.prefix && strings.HasPrefix(word, w.text))
+	})
+}
+
+// searchWords splits the text into lowercase words, as the simple text
+// search configuration does.
+func searchWords(text string) []string {
+	return searchWordRX.FindAllString(strings.ToLower(text), -1)
+}
+
+// normalizeTitle mirrors the normalize_title function of the database.
+func normalizeTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, title)
+}
+
+// memoryMovieModel implements MovieRepository in memory.
+type memoryMovieModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieModel) WithTx(ctx context.Context, fn func(tx MovieRepository) error) error {
+	return m.store.withTx(ctx, func(tx memoryStore) error {
+		return fn(memoryMovieModel{tx})
+	})
+}
+
+func (m memoryMovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
+	var movie *Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) (err error) {
+		movie, err = t.movie(id)
+		return err
+	})
+
+	return movie, err
+}
+
+func (m memoryMovieModel) GetAll(
+	ctx context.Context,
+	q MovieQuery,
+	filters Filters,
+) ([]*Movie, Metadata, error) {
+	var movies []*Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		movies = t.listedMovies(q)
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	if filters.UseCursor {
+		return pageByCursor(movies, filters)
+	}
+
+	sortRows(movies, filters.sortKeys(), false, movieSortValue)
+	movies, metadata := pageRows(movies, filters)
+
+	return movies, metadata, nil
+}
+
+// pageByCursor returns the page of the movies positioned after the cursor
+// of the filters, as MovieModel.getAllByCursor.
+func pageByCursor(movies []*Movie, filters Filters) ([]*Movie, Metadata, error) {
+	var (
+		c   cursor
+		err error
+	)
+
+	totalRecords := 0
+	if filters.IncludeTotal {
+		totalRecords = len(movies)
+	}
+
+	keys := filters.sortKeys()
+
+	if filters.Cursor != "" {
+		if c, err = filters.decodeCursor(); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		after := cursorSortValues(c, keys)
+		movies = slices.DeleteFunc(movies, func(m *Movie) bool {
+			return compareSortValues(keys, c.Backward, sortValues(m, keys, movieSortValue), after) <= 0
+		})
+	}
+
+	sortRows(movies, keys, c.Backward, movieSortValue)
+	movies = movies[:min(len(movies), filters.limit()+1)]
+
+	movies, metadata := cursorPage(movies, c, filters, totalRecords)
+
+	return movies, metadata, nil
+}
+
+func (m memoryMovieModel) Stream(ctx context.Context, q MovieQuery, fn func(movie *Movie) error) error {
+	var movies []*Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		movies = t.listedMovies(q)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := fn(movie); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m memoryMovieModel) Insert(ctx context.Context, movie *Movie, userID int64) error {
+	inserted := *movie
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		return t.insertMovie(&inserted, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	movie.ID, movie.CreatedAt, movie.Version = inserted.ID, inserted.CreatedAt, inserted.Version
+	return nil
+}
+
+func (m memoryMovieModel) InsertBatch(ctx context.Context, movies []*Movie, userID int64) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		for _, movie := range movies {
+			inserted := *movie
+			inserted.ExternalIDs = nil
+
+			if err := t.insertMovie(&inserted, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m memoryMovieModel) Update(ctx context.Context, movie *Movie, userID int64) error {
+	updated := *movie
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		return t.updateMovie(&updated, userID)
+	})
+	if err != nil {
+		return err
+	}
+
+	movie.Version = updated.Version
+	return nil
+}
+
+func (m memoryMovieModel) Delete(ctx context.Context, id int64, version int32) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.movies[id]
+		switch {
+		case version != 0 && (!ok || row.DeletedAt != nil || row.Version != version):
+			return ErrEditConflict
+		case !ok || row.DeletedAt != nil:
+			return ErrRecordNotFound
+		}
+
+		now := memoryNow()
+		row.DeletedAt = &now
+		t.movies[id] = row
+		return nil
+	})
+}
+
+func (m memoryMovieModel) GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	movies := []*Movie{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for _, row := range t.movies {
+			if row.DeletedAt != nil {
+				movie := row.row()
+				movies = append(movies, &movie)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	sortRows(movies, filters.sortKeys(), false, movieSortValue)
+	movies, metadata := pageRows(movies, filters)
+
+	return movies, metadata, nil
+}
+
+func (m memoryMovieModel) Restore(ctx context.Context, id int64) (*Movie, error) {
+	var movie *Movie
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.movies[id]
+		if !ok || row.DeletedAt == nil {
+			return ErrRecordNotFound
+		}
+
+		row.DeletedAt = nil
+		t.movies[id] = row
+
+		movie = t.loadMovie(row)
+		return nil
+	})
+
+	return movie, err
+}
+
+func (m memoryMovieModel) PurgeDeleted(ctx context.Context, before time.Time) (int64, []string, error) {
+	var (
+		purged int64
+		keys   = []string{}
+	)
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		for id, row := range t.movies {
+			if row.DeletedAt != nil && row.DeletedAt.Before(before) {
+				keys = append(keys, t.movieImageKeys(id)...)
+				t.purgeMovie(id)
+				purged++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return purged, keys, nil
+}
+
+// Autocomplete suggests the movies with a word starting with each of the
+// typed words, the shortest titles first.
+func (m memoryMovieModel) Autocomplete(
+	ctx context.Context,
+	text string,
+	limit int,
+	publishedOnly bool,
+) ([]*MovieSuggestion, error) {
+	search := memorySearch{}
+	for _, word := range searchWords(text) {
+		search.add(searchWord{text: word, prefix: true}, false)
+	}
+
+	var rows []Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for _, row := range t.movies {
+			if row.DeletedAt != nil || (publishedOnly && row.Status != MovieStatusPublished) {
+				continue
+			}
+
+			if len(search.groups) > 0 && search.matches(searchWords(row.Title)) {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(rows, func(a, b Movie) int {
+		if c := compareValues(len(a.Title), len(b.Title)); c != 0 {
+			return c
+		}
+		return compareValues(a.ID, b.ID)
+	})
+
+	suggestions := []*MovieSuggestion{}
+	for _, row := range rows[:min(len(rows), limit)] {
+		suggestions = append(suggestions, &MovieSuggestion{ID: row.ID, Title: row.Title, Year: row.Year})
+	}
+
+	return suggestions, nil
+}
+
+func (m memoryMovieModel) Facets(ctx context.Context, q MovieQuery, facets []string) (Facets, error) {
+	var rows []Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		rows = t.matchingRows(q)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(Facets, len(facets))
+	for _, facet := range facets {
+		result[facet] = countFacet(rows, facet)
+	}
+
+	return result, nil
+}
+
+// runtimeBuckets are the runtime facet values, each counting the runtimes
+// below the next one.
+var runtimeBuckets = []struct {
+	value string
+	from  Runtime
+}{
+	{"0-89", 0},
+	{"90-119", 90},
+	{"120-149", 120},
+	{"150+", 150},
+}
+
+// countFacet counts the movies for each value of the facet, ordered as by
+// the queries of movieFacetQueries.
+func countFacet(rows []Movie, facet string) []FacetCount {
+	counts := []FacetCount{}
+
+	add := func(value string) {
+		for i := range counts {
+			if counts[i].Value == value {
+				counts[i].Count++
+				return
+			}
+		}
+		counts = append(counts, FacetCount{Value: value, Count: 1})
+	}
+
+	switch facet {
+	case "genres":
+		for _, row := range rows {
+			for _, genre := range row.Genres {
+				add(genre)
+			}
+		}
+
+		slices.SortFunc(counts, func(a, b FacetCount) int {
+			if c := compareValues(b.Count, a.Count); c != 0 {
+				return c
+			}
+			return strings.Compare(a.Value, b.Value)
+		})
+
+	case "decade":
+		rows = slices.Clone(rows)
+		slices.SortFunc(rows, func(a, b Movie) int { return compareValues(a.Year, b.Year) })
+		for _, row := range rows {
+			add(fmt.Sprintf("%ds", row.Year/10*10))
+		}
+
+	case "runtime":
+		for i, bucket := range runtimeBuckets {
+			for _, row := range rows {
+				if row.Runtime >= bucket.from && (i == len(runtimeBuckets)-1 || row.Runtime < runtimeBuckets[i+1].from) {
+					add(bucket.value)
+				}
+			}
+		}
+
+	default:
+		panic("invalid facet value")
+	}
+
+	return counts
+}
+
+func (m memoryMovieModel) Stats(ctx context.Context, q MovieQuery) (*MovieStats, error) {
+	var rows []Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		rows = t.matchingRows(q)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	stats := MovieStats{Total: len(rows), GeneratedAt: now}
+
+	runtimes := make([]Runtime, len(rows))
+	for i, row := range rows {
+		runtimes[i] = row.Runtime
+
+		if !row.CreatedAt.Before(now.AddDate(0, 0, -7)) {
+			stats.RecentlyAdded.Last7Days++
+		}
+		if !row.CreatedAt.Before(now.AddDate(0, 0, -30)) {
+			stats.RecentlyAdded.Last30Days++
+		}
+	}
+
+	if len(runtimes) > 0 {
+		slices.Sort(runtimes)
+
+		// percentile_disc returns the first value whose position reaches the
+		// fraction of the values.
+		percentile := func(fraction float64) Runtime {
+			return runtimes[max(int(math.Ceil(fraction*float64(len(runtimes))))-1, 0)]
+		}
+
+		stats.Runtime = RuntimeStats{
+			Min:    runtimes[0],
+			Median: percentile(0.5),
+			P90:    percentile(0.9),
+			Max:    runtimes[len(runtimes)-1],
+		}
+	}
+
+	stats.Genres = countFacet(rows, "genres")
+	stats.Decades = countFacet(rows, "decade")
+
+	return &stats, nil
+}
+
+// GetSimilar scores the movies as the query of MovieModel.GetSimilar.
+func (m memoryMovieModel) GetSimilar(
+	ctx context.Context,
+	movie *Movie,
+	q MovieQuery,
+	filters Filters,
+) ([]*Movie, Metadata, error) {
+	movies := []*Movie{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		likers := t.likers(movie.ID)
+		shares := t.likedShares(likers, func(r userMovieKey) bool { return r.movieID != movie.ID })
+
+		for _, row := range t.movies {
+			share, coRated := shares[row.ID]
+			if !inScope(row, q) || row.ID == movie.ID {
+				continue
+			}
+			if !coRated && !slices.ContainsFunc(row.Genres, func(g string) bool {
+				return slices.Contains(movie.Genres, g)
+			}) {
+				continue
+			}
+
+			proximity := max(0, 1-math.Abs(float64(row.Year-movie.Year))/similarYearSpan)
+
+			similar := t.listedMovie(row, q)
+			similar.Relevance = float32(similarGenresWeight*jaccard(row.Genres, movie.Genres) +
+				similarYearWeight*proximity +
+				similarCoRatingWeight*share)
+			movies = append(movies, similar)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	sortRows(movies, filters.sortKeys(), false, movieSortValue)
+	movies, metadata := pageRows(movies, filters)
+
+	return movies, metadata, nil
+}
+
+// GetRecommendations scores the movies as the query of
+// MovieModel.GetRecommendations.
+func (m memoryMovieModel) GetRecommendations(
+	ctx context.Context,
+	userID int64,
+	q MovieQuery,
+	filters Filters,
+) ([]*Movie, Metadata, error) {
+	movies := []*Movie{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		var liked []Movie
+		for key, rating := range t.ratings {
+			if key.userID == userID && rating.Rating >= likedRating {
+				liked = append(liked, t.movies[key.movieID])
+			}
+		}
+
+		weights := make(map[string]float64)
+		for _, movie := range liked {
+			for _, genre := range movie.Genres {
+				weights[genre] += 1 / float64(len(liked))
+			}
+		}
+
+		neighbours := make(map[int64]bool)
+		for _, movie := range liked {
+			for neighbour := range t.likers(movie.ID) {
+				neighbours[neighbour] = true
+			}
+		}
+		delete(neighbours, userID)
+
+		shares := t.likedShares(neighbours, func(userMovieKey) bool { return true })
+		averages := t.averageRatings()
+
+		for _, row := range t.movies {
+			if _, seen := t.ratings[userMovieKey{userID, row.ID}]; seen || !inScope(row, q) {
+				continue
+			}
+
+			var affinity float64
+			for _, genre := range uniqueGenres(row.Genres) {
+				affinity += weights[genre]
+			}
+			affinity /= float64(max(len(row.Genres), 1))
+
+			recommended := t.listedMovie(row, q)
+			recommended.Relevance = float32(recommendedGenresWeight*affinity +
+				recommendedCoRatingWeight*shares[row.ID] +
+				recommendedRatingWeight*averages[row.ID])
+			movies = append(movies, recommended)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	sortRows(movies, filters.sortKeys(), false, movieSortValue)
+	movies, metadata := pageRows(movies, filters)
+
+	return movies, metadata, nil
+}
+
+// likers returns the users who liked the movie.
+func (t *memoryTables) likers(movieID int64) map[int64]bool {
+	likers := make(map[int64]bool)
+	for key, rating := range t.ratings {
+		if key.movieID == movieID && rating.Rating >= likedRating {
+			likers[key.userID] = true
+		}
+	}
+	return likers
+}
+
+// likedShares returns, for each movie liked by some of the users, the share
+// of the users who liked it, only counting the ratings kept by keep.
+func (t *memoryTables) likedShares(users map[int64]bool, keep func(key userMovieKey) bool) map[int64]float64 {
+	shares := make(map[int64]float64)
+	for key, rating := range t.ratings {
+		if users[key.userID] && rating.Rating >= likedRating && keep(key) {
+			shares[key.movieID] += 1 / float64(len(users))
+		}
+	}
+	return shares
+}
+
+// averageRatings returns the average rating of each rated movie, out of 1.
+func (t *memoryTables) averageRatings() map[int64]float64 {
+	sums := make(map[int64]int)
+	counts := make(map[int64]int)
+	for key, rating := range t.ratings {
+		sums[key.movieID] += rating.Rating
+		counts[key.movieID]++
+	}
+
+	averages := make(map[int64]float64, len(sums))
+	for movieID, sum := range sums {
+		averages[movieID] = float64(sum) / float64(counts[movieID]) / 10
+	}
+	return averages
+}
+
+func uniqueGenres(genres []string) []string {
+	unique := slices.Clone(genres)
+	slices.Sort(unique)
+	return slices.Compact(unique)
+}
+
+// jaccard returns the number of genres shared by a and b over the number of
+// their distinct genres.
+func jaccard(a, b []string) float64 {
+	a, b = uniqueGenres(a), uniqueGenres(b)
+
+	shared := 0
+	for _, genre := range a {
+		if slices.Contains(b, genre) {
+			shared++
+		}
+	}
+
+	combined := len(a) + len(b) - shared
+	if combined == 0 {
+		return 0
+	}
+	return float64(shared) / float64(combined)
+}
+
+func (m memoryMovieModel) GetByExternalID(ctx context.Context, e ExternalID) (*Movie, error) {
+	var movie *Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) (err error) {
+		movieID, ok := t.externalIDs[e]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		movie, err = t.movie(movieID)
+		return err
+	})
+
+	return movie, err
+}
+
+func (m memoryMovieModel) FindDuplicate(ctx context.Context, title string, year int32) (*Movie, error) {
+	var movie *Movie
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		var (
+			duplicate Movie
+			found     bool
+		)
+		for _, row := range t.movies {
+			if row.DeletedAt != nil || row.Year != year || normalizeTitle(row.Title) != normalizeTitle(title) {
+				continue
+			}
+
+			if !found || row.ID < duplicate.ID {
+				duplicate, found = row, true
+			}
+		}
+
+		if !found {
+			return ErrRecordNotFound
+		}
+
+		movie = t.loadMovie(duplicate)
+		return nil
+	})
+
+	return movie, err
+}
+
+// Merge moves the children of the source movie to the target as the
+// mergeStatements of MovieModel.Merge.
+func (m memoryMovieModel) Merge(
+	ctx context.Context,
+	targetID, sourceID, userID int64,
+) (*Movie, []string, error) {
+	if targetID < 1 || sourceID < 1 || targetID == sourceID {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	var (
+		merged  *Movie
+		dropped []string
+	)
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		source, err := t.movie(sourceID)
+		if err != nil {
+			return err
+		}
+		if _, err = t.movie(targetID); err != nil {
+			return err
+		}
+
+		for externalID, movieID := range t.externalIDs {
+			if movieID == sourceID {
+				t.externalIDs[externalID] = targetID
+			}
+		}
+		for key, title := range t.titles {
+			if target := (movieChildKey{targetID, key.key}); key.movieID == sourceID {
+				if _, ok := t.titles[target]; !ok {
+					t.titles[target] = title
+				}
+			}
+		}
+		for key, release := range t.releases {
+			if target := (movieChildKey{targetID, key.key}); key.movieID == sourceID {
+				if _, ok := t.releases[target]; !ok {
+					t.releases[target] = release
+				}
+			}
+		}
+		for key, image := range t.images {
+			if target := (movieChildKey{targetID, key.key}); key.movieID == sourceID {
+				if _, ok := t.images[target]; !ok {
+					t.images[target] = image
+					delete(t.images, key)
+				}
+			}
+		}
+		for key, rating := range t.ratings {
+			if target := (userMovieKey{key.userID, targetID}); key.movieID == sourceID {
+				if _, ok := t.ratings[target]; !ok {
+					rating.MovieID = targetID
+					t.ratings[target] = rating
+				}
+			}
+		}
+		for key := range t.movieTags {
+			if key.movieID == sourceID {
+				t.movieTags[movieTagKey{targetID, key.tagID}] = struct{}{}
+			}
+		}
+
+		dropped = t.movieImageKeys(sourceID)
+		t.purgeMovie(sourceID)
+
+		if merged, err = t.movie(targetID); err != nil {
+			return err
+		}
+
+		genres := slices.Clone(merged.Genres)
+		for _, genre := range source.Genres {
+			if len(genres) < 5 && !slices.Contains(genres, genre) {
+				genres = append(genres, genre)
+			}
+		}
+
+		if len(genres) == len(merged.Genres) {
+			return nil
+		}
+
+		merged.Genres = genres
+		return t.updateMovie(merged, userID)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return merged, dropped, nil
+}
+
+func (m memoryMovieModel) SubmitChange(ctx context.Context, change *MovieChange) error {
+	submitted := *change
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.movies[change.MovieID]; !ok {
+			return errMissingReference
+		}
+
+		if change.Kind == ChangeKindPublish {
+			movie := Movie{ID: change.MovieID, Version: change.BaseVersion}
+			if err := t.setMovieStatus(&movie, MovieStatusPendingReview, MovieStatusDraft); err != nil {
+				return err
+			}
+		}
+
+		t.lastIDs.changes++
+
+		submitted.ID = t.lastIDs.changes
+		submitted.Genres = slices.Clone(change.Genres)
+		submitted.Status = ChangeStatusPending
+		submitted.CreatedAt = memoryNow()
+		t.changes[submitted.ID] = submitted
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	change.ID, change.Status, change.CreatedAt = submitted.ID, submitted.Status, submitted.CreatedAt
+	return nil
+}
+
+func (m memoryMovieModel) SetStatus(ctx context.Context, movie *Movie, status string) error {
+	updated := *movie
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		return t.setMovieStatus(&updated, status)
+	})
+	if err != nil {
+		return err
+	}
+
+	movie.Status = status
+	return nil
+}
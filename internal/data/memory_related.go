@@ -0,0 +1,622 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// memoryMovieRevisionModel implements MovieRevisionRepository in memory.
+type memoryMovieRevisionModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieRevisionModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRevision, error) {
+	revisions := []*MovieRevision{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for key, revision := range t.revisions {
+			if key.movieID == movieID {
+				revision := revision
+				revision.Genres = slices.Clone(revision.Genres)
+				revisions = append(revisions, &revision)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	slices.SortFunc(revisions, func(a, b *MovieRevision) int { return compareValues(b.Version, a.Version) })
+
+	return revisions, nil
+}
+
+func (m memoryMovieRevisionModel) Get(
+	ctx context.Context,
+	movieID int64,
+	version int32,
+) (*MovieRevision, error) {
+	var revision MovieRevision
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		var ok bool
+		if revision, ok = t.revisions[movieVersionKey{movieID, version}]; !ok {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	revision.Genres = slices.Clone(revision.Genres)
+
+	return &revision, nil
+}
+
+// memoryMovieImageModel implements MovieImageRepository in memory.
+type memoryMovieImageModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieImageModel) Upsert(ctx context.Context, movieID int64, image *MovieImage) ([]string, error) {
+	var replaced []string
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.movies[movieID]; !ok {
+			return errMissingReference
+		}
+
+		key := movieChildKey{movieID, image.Kind}
+		if old, ok := t.images[key]; ok {
+			replaced = []string{old.Key, old.ThumbnailKey}
+		}
+
+		t.images[key] = *image
+		return nil
+	})
+
+	return replaced, err
+}
+
+func (m memoryMovieImageModel) Delete(ctx context.Context, movieID int64, kind string) ([]string, error) {
+	var keys []string
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		key := movieChildKey{movieID, kind}
+
+		image, ok := t.images[key]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		delete(t.images, key)
+		keys = []string{image.Key, image.ThumbnailKey}
+		return nil
+	})
+
+	return keys, err
+}
+
+// memoryMovieTitleModel implements MovieTitleRepository in memory.
+type memoryMovieTitleModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieTitleModel) Upsert(ctx context.Context, movieID int64, title *MovieTitle) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.movies[movieID]; !ok {
+			return errMissingReference
+		}
+
+		t.titles[movieChildKey{movieID, title.Locale}] = *title
+		return nil
+	})
+}
+
+func (m memoryMovieTitleModel) Delete(ctx context.Context, movieID int64, locale string) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		return deleteChild(t.titles, movieChildKey{movieID, locale})
+	})
+}
+
+// memoryMovieReleaseModel implements MovieReleaseRepository in memory.
+type memoryMovieReleaseModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieReleaseModel) Upsert(ctx context.Context, movieID int64, r *MovieRelease) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.movies[movieID]; !ok {
+			return errMissingReference
+		}
+
+		t.releases[movieChildKey{movieID, r.Country}] = *r
+		return nil
+	})
+}
+
+func (m memoryMovieReleaseModel) Delete(ctx context.Context, movieID int64, country string) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		return deleteChild(t.releases, movieChildKey{movieID, country})
+	})
+}
+
+// deleteChild deletes the row of the table, returning ErrRecordNotFound when
+// there is none, as execOne.
+func deleteChild[K comparable, V any](table map[K]V, key K) error {
+	if _, ok := table[key]; !ok {
+		return ErrRecordNotFound
+	}
+
+	delete(table, key)
+	return nil
+}
+
+// memoryMovieExternalIDModel implements MovieExternalIDRepository in memory.
+type memoryMovieExternalIDModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieExternalIDModel) Insert(ctx context.Context, movieID int64, e ExternalID) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		return t.insertExternalID(movieID, e)
+	})
+}
+
+func (m memoryMovieExternalIDModel) Delete(ctx context.Context, movieID int64, e ExternalID) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if id, ok := t.externalIDs[e]; !ok || id != movieID {
+			return ErrRecordNotFound
+		}
+
+		delete(t.externalIDs, e)
+		return nil
+	})
+}
+
+// memoryMovieChangeModel implements MovieChangeRepository in memory.
+type memoryMovieChangeModel struct {
+	store memoryStore
+}
+
+func (c MovieChange) clone() *MovieChange {
+	c.Genres = slices.Clone(c.Genres)
+	return &c
+}
+
+func (m memoryMovieChangeModel) Get(ctx context.Context, id int64) (*MovieChange, error) {
+	var change *MovieChange
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		row, ok := t.changes[id]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		change = row.clone()
+		return nil
+	})
+
+	return change, err
+}
+
+func (m memoryMovieChangeModel) GetAll(
+	ctx context.Context,
+	status string,
+	filters Filters,
+) ([]*MovieChange, Metadata, error) {
+	changes := []*MovieChange{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for _, change := range t.changes {
+			if status == "" || change.Status == status {
+				changes = append(changes, change.clone())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	sortRows(changes, filters.sortKeys(), false, func(c *MovieChange, column string) any {
+		switch column {
+		case "id":
+			return c.ID
+		default:
+			panic("unsupported sort column " + column)
+		}
+	})
+	changes, metadata := pageRows(changes, filters)
+
+	return changes, metadata, nil
+}
+
+func (m memoryMovieChangeModel) Approve(
+	ctx context.Context,
+	id, reviewerID int64,
+	comment string,
+) (*MovieChange, *Movie, error) {
+	var movie *Movie
+
+	change, err := m.review(ctx, id, func(t *memoryTables, change *MovieChange) error {
+		var err error
+		if movie, err = t.movie(change.MovieID); err != nil {
+			return err
+		}
+
+		if movie.Version != change.BaseVersion {
+			return ErrEditConflict
+		}
+
+		change.Status = ChangeStatusApproved
+
+		if change.Kind == ChangeKindPublish {
+			return t.setMovieStatus(movie, MovieStatusPublished)
+		}
+
+		change.Apply(movie)
+		return t.updateMovie(movie, change.UserID)
+	}, reviewerID, comment)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return change, movie, nil
+}
+
+func (m memoryMovieChangeModel) Reject(
+	ctx context.Context,
+	id, reviewerID int64,
+	comment string,
+) (*MovieChange, error) {
+	return m.review(ctx, id, func(t *memoryTables, change *MovieChange) error {
+		change.Status = ChangeStatusRejected
+
+		if row, ok := t.movies[change.MovieID]; ok && change.Kind == ChangeKindPublish {
+			if row.Status == MovieStatusPendingReview {
+				row.Status = MovieStatusDraft
+				t.movies[row.ID] = row
+			}
+		}
+
+		return nil
+	}, reviewerID, comment)
+}
+
+// review lets decide set the status of the pending change and update the
+// movie accordingly, then saves the review, as MovieChangeModel.review.
+func (m memoryMovieChangeModel) review(
+	ctx context.Context,
+	id int64,
+	decide func(t *memoryTables, change *MovieChange) error,
+	reviewerID int64,
+	comment string,
+) (*MovieChange, error) {
+	var change *MovieChange
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.changes[id]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		if row.Status != ChangeStatusPending {
+			return ErrChangeReviewed
+		}
+
+		change = row.clone()
+		if err := decide(t, change); err != nil {
+			return err
+		}
+
+		now := memoryNow()
+		change.ReviewerID = reviewerID
+		change.Comment = comment
+		change.ReviewedAt = &now
+
+		t.changes[id] = *change.clone()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// memoryMovieRatingModel implements MovieRatingRepository in memory.
+type memoryMovieRatingModel struct {
+	store memoryStore
+}
+
+func (m memoryMovieRatingModel) Upsert(ctx context.Context, userID int64, r *MovieRating) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[userID]; !ok {
+			return errMissingReference
+		}
+		if _, ok := t.movies[r.MovieID]; !ok {
+			return errMissingReference
+		}
+
+		r.UpdatedAt = memoryNow()
+		t.ratings[userMovieKey{userID, r.MovieID}] = *r
+		return nil
+	})
+}
+
+func (m memoryMovieRatingModel) Get(ctx context.Context, userID, movieID int64) (*MovieRating, error) {
+	var rating MovieRating
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		var ok bool
+		if rating, ok = t.ratings[userMovieKey{userID, movieID}]; !ok {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rating, nil
+}
+
+func (m memoryMovieRatingModel) Delete(ctx context.Context, userID, movieID int64) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		return deleteChild(t.ratings, userMovieKey{userID, movieID})
+	})
+}
+
+// memoryTagModel implements TagRepository in memory.
+type memoryTagModel struct {
+	store memoryStore
+}
+
+// tag returns the tag along with the number of movies it is used by.
+func (t *memoryTables) tag(id int64) *Tag {
+	tag := t.tags[id]
+	for key := range t.movieTags {
+		if key.tagID == id {
+			tag.Uses++
+		}
+	}
+	return &tag
+}
+
+func (t *memoryTables) tagByName(name string) (Tag, bool) {
+	for _, tag := range t.tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return Tag{}, false
+}
+
+func (m memoryTagModel) AddToMovie(
+	ctx context.Context,
+	movieID int64,
+	names []string,
+	userID int64,
+	approve bool,
+) error {
+	status := TagStatusPending
+	if approve {
+		status = TagStatusApproved
+	}
+
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.movies[movieID]; !ok {
+			return errMissingReference
+		}
+
+		for _, name := range names {
+			tag, ok := t.tagByName(name)
+			if !ok {
+				t.lastIDs.tags++
+
+				tag = Tag{ID: t.lastIDs.tags, Name: name, Status: status, CreatedAt: memoryNow()}
+				t.tags[tag.ID] = tag
+			}
+
+			if tag.Status == TagStatusRejected {
+				return fmt.Errorf("%w: %s", ErrTagRejected, name)
+			}
+
+			t.movieTags[movieTagKey{movieID, tag.ID}] = struct{}{}
+		}
+
+		count := 0
+		for key := range t.movieTags {
+			if key.movieID == movieID {
+				count++
+			}
+		}
+
+		if count > MaxMovieTags {
+			return ErrTooManyTags
+		}
+
+		return nil
+	})
+}
+
+func (m memoryTagModel) RemoveFromMovie(ctx context.Context, movieID int64, name string) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		tag, ok := t.tagByName(name)
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		return deleteChild(t.movieTags, movieTagKey{movieID, tag.ID})
+	})
+}
+
+func (m memoryTagModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*Tag, error) {
+	tags := []*Tag{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for key := range t.movieTags {
+			if key.movieID == movieID {
+				tags = append(tags, t.tag(key.tagID))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(tags, func(a, b *Tag) int { return strings.Compare(a.Name, b.Name) })
+
+	return tags, nil
+}
+
+func (m memoryTagModel) Autocomplete(ctx context.Context, prefix string, limit int) ([]*Tag, error) {
+	tags := []*Tag{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for id, tag := range t.tags {
+			if tag.Status == TagStatusApproved && strings.HasPrefix(tag.Name, prefix) {
+				tags = append(tags, t.tag(id))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(tags, func(a, b *Tag) int {
+		if c := compareValues(b.Uses, a.Uses); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return tags[:min(len(tags), limit)], nil
+}
+
+func (m memoryTagModel) GetAll(ctx context.Context, status string, filters Filters) ([]*Tag, Metadata, error) {
+	tags := []*Tag{}
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for id, tag := range t.tags {
+			if status == "" || tag.Status == status {
+				tags = append(tags, t.tag(id))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	sortRows(tags, filters.sortKeys(), false, func(tag *Tag, column string) any {
+		switch column {
+		case "id":
+			return tag.ID
+		case "name":
+			return tag.Name
+		default:
+			panic("unsupported sort column " + column)
+		}
+	})
+	tags, metadata := pageRows(tags, filters)
+
+	return tags, metadata, nil
+}
+
+func (m memoryTagModel) SetStatus(ctx context.Context, id int64, status string) (*Tag, error) {
+	var tag *Tag
+
+	err := m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.tags[id]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		row.Status = status
+		t.tags[id] = row
+
+		tag = t.tag(id)
+		return nil
+	})
+
+	return tag, err
+}
+
+// memoryImportJobModel implements ImportJobRepository in memory.
+type memoryImportJobModel struct {
+	store memoryStore
+}
+
+func (j ImportJob) clone() *ImportJob {
+	j.Report.Errors = slices.Clone(j.Report.Errors)
+	return &j
+}
+
+func (m memoryImportJobModel) Insert(ctx context.Context, job *ImportJob) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[job.UserID]; !ok {
+			return errMissingReference
+		}
+
+		t.lastIDs.importJobs++
+
+		job.ID = t.lastIDs.importJobs
+		job.CreatedAt = memoryNow()
+		job.UpdatedAt = job.CreatedAt
+		t.importJobs[job.ID] = ImportJob{
+			ID:        job.ID,
+			UserID:    job.UserID,
+			Format:    job.Format,
+			Status:    job.Status,
+			CreatedAt: job.CreatedAt,
+			UpdatedAt: job.UpdatedAt,
+		}
+		return nil
+	})
+}
+
+func (m memoryImportJobModel) Update(ctx context.Context, job *ImportJob) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.importJobs[job.ID]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		row.Status = job.Status
+		row.Report = job.clone().Report
+		row.Error = job.Error
+		row.UpdatedAt = memoryNow()
+		t.importJobs[job.ID] = row
+
+		job.UpdatedAt = row.UpdatedAt
+		return nil
+	})
+}
+
+func (m memoryImportJobModel) Get(ctx context.Context, id int64) (*ImportJob, error) {
+	var job *ImportJob
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		row, ok := t.importJobs[id]
+		if !ok {
+			return ErrRecordNotFound
+		}
+
+		job = row.clone()
+		return nil
+	})
+
+	return job, err
+}
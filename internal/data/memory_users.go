@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// memoryPermissionCodes are the permissions inserted by the migrations.
+var memoryPermissionCodes = []string{"movies:read", "movies:write", "movies:admin", "movies:publish"}
+
+// memoryUserModel implements UserRepository in memory. Emails are compared
+// ignoring case, as the citext column is.
+type memoryUserModel struct {
+	store memoryStore
+}
+
+// userByEmail returns the user with the email, ignoring case.
+func (t *memoryTables) userByEmail(email string) (User, bool) {
+	for _, user := range t.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (m memoryUserModel) Insert(ctx context.Context, user *User) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.userByEmail(user.Email); ok {
+			return ErrDuplicateEmail
+		}
+
+		t.lastIDs.users++
+
+		user.ID = t.lastIDs.users
+		user.CreatedAt = memoryNow()
+		user.Version = 1
+		t.users[user.ID] = User{
+			ID:        user.ID,
+			CreatedAt: user.CreatedAt,
+			Name:      user.Name,
+			Email:     user.Email,
+			Password:  password{hash: user.Password.hash},
+			Activated: user.Activated,
+			Version:   user.Version,
+		}
+		return nil
+	})
+}
+
+func (m memoryUserModel) GetByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		var ok bool
+		if user, ok = t.userByEmail(email); !ok {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (m memoryUserModel) Update(ctx context.Context, user *User) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		row, ok := t.users[user.ID]
+		if !ok || row.Version != user.Version {
+			return ErrEditConflict
+		}
+
+		if other, ok := t.userByEmail(user.Email); ok && other.ID != user.ID {
+			return ErrDuplicateEmail
+		}
+
+		row.Name = user.Name
+		row.Email = user.Email
+		row.Password = password{hash: user.Password.hash}
+		row.Activated = user.Activated
+		row.Version++
+		t.users[user.ID] = row
+
+		user.Version = row.Version
+		return nil
+	})
+}
+
+func (m memoryUserModel) GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error) {
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+
+	var user User
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		token, ok := t.tokens[string(hash[:])]
+		if !ok || token.Scope != scope || !token.Expiry.After(time.Now()) {
+			return ErrRecordNotFound
+		}
+
+		if user, ok = t.users[token.UserID]; !ok {
+			return ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// memoryTokenModel implements TokenRepository in memory.
+type memoryTokenModel struct {
+	store memoryStore
+}
+
+func (m memoryTokenModel) New(
+	ctx context.Context,
+	userID int64,
+	ttl time.Duration,
+	scope string,
+) (*Token, error) {
+	token, err := generateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(ctx, token)
+
+	return token, err
+}
+
+func (m memoryTokenModel) Insert(ctx context.Context, token *Token) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[token.UserID]; !ok {
+			return errMissingReference
+		}
+
+		t.tokens[string(token.Hash)] = Token{
+			Hash:   slices.Clone(token.Hash),
+			UserID: token.UserID,
+			Expiry: token.Expiry,
+			Scope:  token.Scope,
+		}
+		return nil
+	})
+}
+
+func (m memoryTokenModel) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		for hash, token := range t.tokens {
+			if token.Scope == scope && token.UserID == userID {
+				delete(t.tokens, hash)
+			}
+		}
+		return nil
+	})
+}
+
+// memoryPermissionModel implements PermissionRepository in memory.
+type memoryPermissionModel struct {
+	store memoryStore
+}
+
+func (m memoryPermissionModel) GetAllForUser(ctx context.Context, userID int64) (Permissions, error) {
+	var permissions Permissions
+
+	err := m.store.view(ctx, func(t *memoryTables) error {
+		for key := range t.permissions {
+			if key.userID == userID {
+				permissions = append(permissions, key.code)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(permissions)
+
+	return permissions, nil
+}
+
+// AddForUser grants the permissions to the user, ignoring unknown codes as
+// the query does. Granting a permission twice fails like the primary key.
+func (m memoryPermissionModel) AddForUser(ctx context.Context, userID int64, codes ...string) error {
+	return m.store.update(ctx, func(t *memoryTables) error {
+		if _, ok := t.users[userID]; !ok && len(codes) > 0 {
+			return errMissingReference
+		}
+
+		for _, code := range codes {
+			if !slices.Contains(memoryPermissionCodes, code) {
+				continue
+			}
+
+			key := userPermissionKey{userID, code}
+			if _, ok := t.permissions[key]; ok {
+				return fmt.Errorf("memory: user %d already has permission %s", userID, code)
+			}
+
+			t.permissions[key] = struct{}{}
+		}
+		return nil
+	})
+}
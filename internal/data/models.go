@@ -1,8 +1,12 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"time"
 )
 
 var (
@@ -11,17 +15,110 @@ var (
 )
 
 type Models struct {
-	Movies      MovieModel
-	Users       UserModel
-	Tokens      TokenModel
-	Permissions PermissionModel
+	Movies         MovieRepository
+	MovieRevisions MovieRevisionRepository
+	MovieImages    MovieImageRepository
+	MovieTitles    MovieTitleRepository
+	MovieReleases  MovieReleaseRepository
+	ExternalIDs    MovieExternalIDRepository
+	MovieChanges   MovieChangeRepository
+	MovieRatings   MovieRatingRepository
+	Tags           TagRepository
+	Users          UserRepository
+	Tokens         TokenRepository
+	Permissions    PermissionRepository
+	ImportJobs     ImportJobRepository
+
+	withTx func(ctx context.Context, fn func(tx Models) error) error
+}
+
+// NewModels returns the models of the database, whose queries each run for
+// at most timeout.
+func NewModels(db *sql.DB, timeout time.Duration) Models {
+	return newModels(db, timeout)
 }
 
-func NewModels(db *sql.DB) Models {
+func newModels(db DBTX, timeout time.Duration) Models {
 	return Models{
-		Movies:      MovieModel{DB: db},
-		Users:       UserModel{DB: db},
-		Tokens:      TokenModel{DB: db},
-		Permissions: PermissionModel{DB: db},
+		Movies:         MovieModel{DB: db, Timeout: timeout},
+		MovieRevisions: MovieRevisionModel{DB: db, Timeout: timeout},
+		MovieImages:    MovieImageModel{DB: db, Timeout: timeout},
+		MovieTitles:    MovieTitleModel{DB: db, Timeout: timeout},
+		MovieReleases:  MovieReleaseModel{DB: db, Timeout: timeout},
+		ExternalIDs:    MovieExternalIDModel{DB: db, Timeout: timeout},
+		MovieChanges:   MovieChangeModel{DB: db, Timeout: timeout},
+		MovieRatings:   MovieRatingModel{DB: db, Timeout: timeout},
+		Tags:           TagModel{DB: db, Timeout: timeout},
+		Users:          UserModel{DB: db, Timeout: timeout},
+		Tokens:         TokenModel{DB: db, Timeout: timeout},
+		Permissions:    PermissionModel{DB: db, Timeout: timeout},
+		ImportJobs:     ImportJobModel{DB: db, Timeout: timeout},
+		withTx: func(ctx context.Context, fn func(tx Models) error) error {
+			return inTx(ctx, db, func(tx DBTX) error {
+				return fn(newModels(tx, timeout))
+			})
+		},
+	}
+}
+
+// WithTx runs fn with models whose queries all belong to a single
+// transaction, which is committed when fn returns nil and rolled back
+// otherwise. Called on the models of a transaction, fn simply becomes part
+// of it.
+func (m Models) WithTx(ctx context.Context, fn func(tx Models) error) error {
+	return m.withTx(ctx, fn)
+}
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so that models can run
+// their queries on their own or as part of a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// inTx runs fn in a new transaction, unless db already is one, in which case
+// fn simply becomes part of it.
+func inTx(ctx context.Context, db DBTX, fn func(tx DBTX) error) error {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db)
+	}
+
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// queryArgs collects the arguments of a query built from several parts.
+type queryArgs []any
+
+// add appends value to the arguments and returns its placeholder.
+func (a *queryArgs) add(value any) string {
+	*a = append(*a, value)
+	return fmt.Sprintf("$%d", len(*a))
+}
+
+// scanJSON implements sql.Scanner for values selected as JSON, such as the
+// aggregated children of a row.
+func scanJSON(src any, dst any) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, dst)
+	case string:
+		return json.Unmarshal([]byte(src), dst)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T as JSON", src)
 	}
 }
@@ -0,0 +1,411 @@
+package data_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+// forEachModels runs fn against the memory models and, when
+// GREENLIGHT_TEST_DB_DSN is set, against the PostgreSQL models, so that both
+// implementations are held to the same behaviour. The database must be
+// migrated; its movies, users and their related rows are removed first.
+func forEachModels(t *testing.T, fn func(t *testing.T, models data.Models)) {
+	t.Run("memory", func(t *testing.T) {
+		fn(t, data.NewMemoryModels())
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dsn := os.Getenv("GREENLIGHT_TEST_DB_DSN")
+		if dsn == "" {
+			t.Skip("GREENLIGHT_TEST_DB_DSN is not set")
+		}
+
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+
+		_, err = db.Exec(`TRUNCATE movies, users, tags, import_jobs RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fn(t, data.NewModels(db, 3*time.Second))
+	})
+}
+
+func insertTestMovies(t *testing.T, models data.Models) {
+	t.Helper()
+
+	movies := []*data.Movie{
+		{Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}},
+		{Title: "Black Panther", Year: 2018, Runtime: 134, Genres: []string{"action"}},
+		{Title: "Deadpool", Year: 2016, Runtime: 108, Genres: []string{"action", "comedy"}},
+		{Title: "The Breakfast Club", Year: 1985, Runtime: 96, Genres: []string{"drama"}},
+	}
+
+	for _, movie := range movies {
+		movie.Status = data.MovieStatusPublished
+		if err := models.Movies.Insert(context.Background(), movie, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// testPassword is hashed once for every test user, bcrypt being slow on
+// purpose.
+var testPassword = sync.OnceValues(func() (data.User, error) {
+	var user data.User
+	err := user.Password.Set("pa55word")
+	return user, err
+})
+
+// newTestUser returns a user ready to be inserted, with the password the
+// database requires.
+func newTestUser(t *testing.T, name, email string) *data.User {
+	t.Helper()
+
+	hashed, err := testPassword()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &data.User{Name: name, Email: email, Password: hashed.Password}
+}
+
+func movieTitles(movies []*data.Movie) []string {
+	titles := []string{}
+	for _, movie := range movies {
+		titles = append(titles, movie.Title)
+	}
+	return titles
+}
+
+func TestMovieUpdateConflict(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		insertTestMovies(t, models)
+
+		first, err := models.Movies.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := models.Movies.Get(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		first.Title = "Moana 2"
+		if err := models.Movies.Update(ctx, first, 0); err != nil {
+			t.Fatal(err)
+		}
+		if first.Version != 2 {
+			t.Errorf("expected version 2, got %d", first.Version)
+		}
+
+		second.Year = 2024
+		if err := models.Movies.Update(ctx, second, 0); !errors.Is(err, data.ErrEditConflict) {
+			t.Errorf("expected %v, got %v", data.ErrEditConflict, err)
+		}
+
+		if err := models.Movies.Delete(ctx, 1, second.Version); !errors.Is(err, data.ErrEditConflict) {
+			t.Errorf("expected %v, got %v", data.ErrEditConflict, err)
+		}
+		if err := models.Movies.Delete(ctx, 1, first.Version); err != nil {
+			t.Errorf("expected the current version to be deleted, got %v", err)
+		}
+
+		if _, err := models.Movies.Get(ctx, 42); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v, got %v", data.ErrRecordNotFound, err)
+		}
+	})
+}
+
+func TestUserDuplicateEmail(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		alice := newTestUser(t, "Alice", "alice@example.com")
+		if err := models.Users.Insert(ctx, alice); err != nil {
+			t.Fatal(err)
+		}
+
+		duplicate := newTestUser(t, "Alice", "Alice@Example.com")
+		if err := models.Users.Insert(ctx, duplicate); !errors.Is(err, data.ErrDuplicateEmail) {
+			t.Errorf("expected %v, got %v", data.ErrDuplicateEmail, err)
+		}
+
+		bob := newTestUser(t, "Bob", "bob@example.com")
+		if err := models.Users.Insert(ctx, bob); err != nil {
+			t.Fatal(err)
+		}
+
+		bob.Email = "ALICE@example.com"
+		if err := models.Users.Update(ctx, bob); !errors.Is(err, data.ErrDuplicateEmail) {
+			t.Errorf("expected %v, got %v", data.ErrDuplicateEmail, err)
+		}
+	})
+}
+
+func TestModelsWithTx(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		errRollback := errors.New("rollback")
+
+		err := models.WithTx(ctx, func(tx data.Models) error {
+			user := newTestUser(t, "Alice", "alice@example.com")
+			if err := tx.Users.Insert(ctx, user); err != nil {
+				return err
+			}
+
+			if _, err := tx.Users.GetByEmail(ctx, user.Email); err != nil {
+				return err
+			}
+
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Fatalf("expected %v, got %v", errRollback, err)
+		}
+
+		if _, err := models.Users.GetByEmail(ctx, "alice@example.com"); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v, got %v", data.ErrRecordNotFound, err)
+		}
+	})
+}
+
+func TestMovieGetAll(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		insertTestMovies(t, models)
+
+		safelist := []string{"id", "title", "year", "runtime", "-id", "-title", "-year", "-runtime"}
+
+		tests := []struct {
+			desc           string
+			query          data.MovieQuery
+			filters        data.Filters
+			expectedTitles []string
+			expectedMeta   data.Metadata
+		}{
+			{
+				desc:           "by id",
+				filters:        data.Filters{Page: 1, PageSize: 20, Sort: "id"},
+				expectedTitles: []string{"Moana", "Black Panther", "Deadpool", "The Breakfast Club"},
+				expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 4},
+			},
+			{
+				desc:           "by title",
+				query:          data.MovieQuery{Title: "panther"},
+				filters:        data.Filters{Page: 1, PageSize: 20, Sort: "id"},
+				expectedTitles: []string{"Black Panther"},
+				expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 1},
+			},
+			{
+				desc:           "by genre, descending runtime",
+				query:          data.MovieQuery{Genres: []string{"action"}},
+				filters:        data.Filters{Page: 1, PageSize: 20, Sort: "-runtime"},
+				expectedTitles: []string{"Black Panther", "Deadpool"},
+				expectedMeta:   data.Metadata{CurrentPage: 1, PageSize: 20, FirstPage: 1, LastPage: 1, TotalRecords: 2},
+			},
+			{
+				desc:           "second page by year",
+				filters:        data.Filters{Page: 2, PageSize: 2, Sort: "year"},
+				expectedTitles: []string{"Deadpool", "Black Panther"},
+				expectedMeta:   data.Metadata{CurrentPage: 2, PageSize: 2, FirstPage: 1, LastPage: 2, TotalRecords: 4},
+			},
+			{
+				desc:           "no match",
+				query:          data.MovieQuery{Genres: []string{"western"}},
+				filters:        data.Filters{Page: 1, PageSize: 20, Sort: "id"},
+				expectedTitles: []string{},
+				expectedMeta:   data.Metadata{},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.desc, func(t *testing.T) {
+				tt.filters.SortSafelist = safelist
+
+				movies, meta, err := models.Movies.GetAll(context.Background(), tt.query, tt.filters)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if diff := cmp.Diff(tt.expectedTitles, movieTitles(movies)); diff != "" {
+					t.Errorf("titles does not match (-want, +got):\n%s", diff)
+				}
+				if diff := cmp.Diff(tt.expectedMeta, meta); diff != "" {
+					t.Errorf("metadata does not match (-want, +got):\n%s", diff)
+				}
+			})
+		}
+	})
+}
+
+func TestMovieGetAllByCursor(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		insertTestMovies(t, models)
+
+		filters := data.Filters{
+			PageSize:     3,
+			Sort:         "title",
+			SortSafelist: []string{"title"},
+			UseCursor:    true,
+			CursorSecret: []byte("secret"),
+		}
+
+		movies, meta, err := models.Movies.GetAll(ctx, data.MovieQuery{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Black Panther", "Deadpool", "Moana"}, movieTitles(movies)); diff != "" {
+			t.Errorf("first page does not match (-want, +got):\n%s", diff)
+		}
+
+		filters.Cursor = meta.NextCursor
+		movies, meta, err = models.Movies.GetAll(ctx, data.MovieQuery{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"The Breakfast Club"}, movieTitles(movies)); diff != "" {
+			t.Errorf("second page does not match (-want, +got):\n%s", diff)
+		}
+		if meta.NextCursor != "" {
+			t.Errorf("expected no next cursor on the last page, got %q", meta.NextCursor)
+		}
+
+		filters.Cursor = meta.PrevCursor
+		movies, _, err = models.Movies.GetAll(ctx, data.MovieQuery{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Black Panther", "Deadpool", "Moana"}, movieTitles(movies)); diff != "" {
+			t.Errorf("previous page does not match (-want, +got):\n%s", diff)
+		}
+	})
+}
+
+func TestMovieTrash(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+		insertTestMovies(t, models)
+
+		filters := data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSafelist: []string{"id"}}
+
+		for _, id := range []int64{1, 3} {
+			if err := models.Movies.Delete(ctx, id, 0); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		trashed, _, err := models.Movies.GetAllDeleted(ctx, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Moana", "Deadpool"}, movieTitles(trashed)); diff != "" {
+			t.Errorf("trash does not match (-want, +got):\n%s", diff)
+		}
+
+		if _, err := models.Movies.Get(ctx, 1); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v for a trashed movie, got %v", data.ErrRecordNotFound, err)
+		}
+
+		restored, err := models.Movies.Restore(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if restored.Title != "Moana" {
+			t.Errorf("expected Moana to be restored, got %q", restored.Title)
+		}
+		if _, err := models.Movies.Restore(ctx, 2); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v when restoring a movie not in the trash, got %v", data.ErrRecordNotFound, err)
+		}
+
+		purged, _, err := models.Movies.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != 0 {
+			t.Errorf("expected no movie deleted an hour ago to be purged, got %d", purged)
+		}
+
+		purged, _, err = models.Movies.PurgeDeleted(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if purged != 1 {
+			t.Errorf("expected 1 movie to be purged, got %d", purged)
+		}
+
+		if _, err := models.Movies.Restore(ctx, 3); !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v when restoring a purged movie, got %v", data.ErrRecordNotFound, err)
+		}
+
+		movies, _, err := models.Movies.GetAll(ctx, data.MovieQuery{}, filters)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Moana", "Black Panther", "The Breakfast Club"}, movieTitles(movies)); diff != "" {
+			t.Errorf("movies does not match (-want, +got):\n%s", diff)
+		}
+	})
+}
+
+func TestUserTokens(t *testing.T) {
+	forEachModels(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		user := newTestUser(t, "Alice", "alice@example.com")
+		if err := models.Users.Insert(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		if err := models.Permissions.AddForUser(ctx, user.ID, "movies:read"); err != nil {
+			t.Fatal(err)
+		}
+
+		token, err := models.Tokens.New(ctx, user.ID, time.Hour, data.ScopeAuthentication)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := models.Users.GetForToken(ctx, data.ScopeAuthentication, token.Plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID != user.ID {
+			t.Errorf("expected user %d, got %d", user.ID, got.ID)
+		}
+
+		permissions, err := models.Permissions.GetAllForUser(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(data.Permissions{"movies:read"}, permissions); diff != "" {
+			t.Errorf("permissions does not match (-want, +got):\n%s", diff)
+		}
+
+		_, err = models.Users.GetForToken(ctx, data.ScopeActivation, token.Plaintext)
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v for another scope, got %v", data.ErrRecordNotFound, err)
+		}
+
+		if err := models.Tokens.DeleteAllForUser(ctx, data.ScopeAuthentication, user.ID); err != nil {
+			t.Fatal(err)
+		}
+		_, err = models.Users.GetForToken(ctx, data.ScopeAuthentication, token.Plaintext)
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			t.Errorf("expected %v for a deleted token, got %v", data.ErrRecordNotFound, err)
+		}
+	})
+}
@@ -3,8 +3,10 @@ package data
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/lib/pq"
@@ -12,16 +14,74 @@ import (
 	"github.com/zmwilliam/greenlight/internal/validator"
 )
 
-const contextTimeout = 3 * time.Second
+const (
+	batchContextTimeout  = 30 * time.Second
+	streamContextTimeout = 10 * time.Minute
+)
 
 type Movie struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"-"`
 	Title     string    `json:"title"`
-	Year      int32     `json:"year"`
-	Runtime   Runtime   `json:"runtime"`
-	Genres    []string  `json:"genres"`
-	Version   int32     `json:"version"`
+
+	// OriginalTitle is only set when Title was localized.
+	OriginalTitle string `json:"original_title,omitempty"`
+
+	Year      int32      `json:"year"`
+	Runtime   Runtime    `json:"runtime"`
+	Genres    []string   `json:"genres"`
+	Version   int32      `json:"version"`
+	Status    string     `json:"status"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	Images MovieImages `json:"images,omitempty"`
+
+	// Titles, Releases, ExternalIDs and Tags are only loaded along with a
+	// single movie, or in listings that include them.
+	Titles      MovieTitles   `json:"titles,omitempty"`
+	Releases    MovieReleases `json:"releases,omitempty"`
+	ExternalIDs ExternalIDs   `json:"external_ids,omitempty"`
+	Tags        MovieTags     `json:"tags,omitempty"`
+
+	// Headline and Relevance are only set when listing movies by a search.
+	// Relevance is also the score of similar and recommended movies.
+	Headline  string  `json:"headline,omitempty"`
+	Relevance float32 `json:"relevance,omitempty"`
+
+	// RuntimeFormat is the format the runtime is written in, the default
+	// one when empty.
+	RuntimeFormat string `json:"-"`
+}
+
+func (m Movie) MarshalJSON() ([]byte, error) {
+	type movie Movie
+	return json.Marshal(struct {
+		movie
+		Runtime any `json:"runtime"`
+	}{movie(m), m.Runtime.Format(m.RuntimeFormat)})
+}
+
+// cursor returns the keyset position of the movie for the given filters,
+// whose sort columns Filters.Validate restricts to cursorSorts.
+func (m *Movie) cursor(f Filters, backward bool) cursor {
+	keys := f.sortKeys()
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		switch k.column {
+		case "id":
+			values[i] = m.ID
+		case "title":
+			values[i] = m.originalTitle()
+		case "year":
+			values[i] = m.Year
+		case "runtime":
+			values[i] = int32(m.Runtime)
+		default:
+			panic("unsupported cursor column " + k.column)
+		}
+	}
+
+	return cursor{Sort: f.Sort, Values: values, Backward: backward}
 }
 
 func ValidateMovie(v *validator.Validator, m *Movie) {
@@ -42,45 +102,277 @@ func ValidateMovie(v *validator.Validator, m *Movie) {
 	v.Check(validator.Unique(m.Genres), "genres", "must not contain duplicate values")
 }
 
+// movieColumns are selected by the queries returning a single movie, which
+// load its children along with it.
+const movieColumns = `id, created_at, title, year, runtime, genres, version, status, ` +
+	movieImagesColumn + `, ` + movieTitlesColumn + `, ` + movieReleasesColumn + `, ` +
+	movieExternalIDsColumn + `, ` + movieTagsColumn
+
+// scanMovie scans a row of movieColumns.
+func scanMovie(row *sql.Row) (*Movie, error) {
+	var movie Movie
+
+	err := row.Scan(
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+		&movie.Status,
+		&movie.Images,
+		&movie.Titles,
+		&movie.Releases,
+		&movie.ExternalIDs,
+		&movie.Tags,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &movie, nil
+}
+
 type MovieModel struct {
-	DB *sql.DB
+	DB      DBTX
+	Timeout time.Duration
 }
 
-func (m MovieModel) GetAll(
-	title string,
-	genres []string,
+// WithTx runs fn with a MovieModel whose queries all belong to a single
+// transaction, which is committed when fn returns nil and rolled back
+// otherwise.
+func (m MovieModel) WithTx(ctx context.Context, fn func(tx MovieRepository) error) error {
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		return fn(MovieModel{DB: tx, Timeout: m.Timeout})
+	})
+}
+
+func (m MovieModel) GetAll(ctx context.Context, q MovieQuery, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.UseCursor {
+		return m.getAllByCursor(ctx, q, filters)
+	}
+
+	var args queryArgs
+
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), %s
+		FROM movies
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s OFFSET %s`,
+		q.columns(&args),
+		q.where(&args),
+		filters.OrderBy(),
+		args.add(filters.limit()),
+		args.add(filters.offset()),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	movies, totalRecords, err := m.queryMovies(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	// Past the last page, there is no row to carry the count.
+	if len(movies) == 0 && filters.Page > 1 {
+		if totalRecords, err = m.count(ctx, q); err != nil {
+			return nil, Metadata{}, err
+		}
+	}
+
+	metadata := newMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// count returns the number of movies matching the query.
+func (m MovieModel) count(ctx context.Context, q MovieQuery) (int, error) {
+	var args queryArgs
+
+	query := "SELECT count(*) FROM movies WHERE " + q.where(&args)
+
+	var total int
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&total)
+
+	return total, err
+}
+
+// getAllByCursor pages through movies using the sort key of the last seen
+// row instead of an offset. The total count is only computed on request.
+func (m MovieModel) getAllByCursor(
+	ctx context.Context,
+	q MovieQuery,
 	filters Filters,
 ) ([]*Movie, Metadata, error) {
-	baseQuery := `
-		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version
+	var (
+		c    cursor
+		err  error
+		args queryArgs
+	)
+
+	columns := q.columns(&args)
+	where := q.where(&args)
+
+	if filters.Cursor != "" {
+		if c, err = filters.decodeCursor(); err != nil {
+			return nil, Metadata{}, err
+		}
+
+		where += " AND " + filters.keysetClause(c, &args)
+	}
+
+	total := "0"
+	if filters.IncludeTotal {
+		total = "(SELECT count(*) FROM movies WHERE " + q.where(&args) + ")"
+	}
+
+	// Fetch one extra row to find out whether there is another page.
+	query := fmt.Sprintf(`
+		SELECT %s, %s
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) or $1 = '')
-		AND (genres @> $2 or $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s`,
+		total,
+		columns,
+		where,
+		filters.orderClause(c.Backward),
+		args.add(filters.limit()+1),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	movies, totalRecords, err := m.queryMovies(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	movies, metadata := cursorPage(movies, c, filters, totalRecords)
+
+	return movies, metadata, nil
+}
+
+// cursorPage turns the movies fetched after the cursor, including the extra
+// one telling whether there is another page, into the page of movies in sort
+// order along with the cursors of the pages around it.
+func cursorPage(movies []*Movie, c cursor, filters Filters, totalRecords int) ([]*Movie, Metadata) {
+	hasMore := len(movies) > filters.PageSize
+	if hasMore {
+		movies = movies[:filters.PageSize]
+	}
 
-	query := fmt.Sprintf(baseQuery, filters.SortValue(), filters.SortDirection())
+	if c.Backward {
+		slices.Reverse(movies)
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize, TotalRecords: totalRecords}
+
+	if len(movies) > 0 {
+		hasNext := (!c.Backward && hasMore) || (c.Backward && filters.Cursor != "")
+		hasPrev := (c.Backward && hasMore) || (!c.Backward && filters.Cursor != "")
+
+		if hasNext {
+			metadata.NextCursor = filters.encodeCursor(movies[len(movies)-1].cursor(filters, false))
+		}
+		if hasPrev {
+			metadata.PrevCursor = filters.encodeCursor(movies[0].cursor(filters, true))
+		}
+	}
+
+	return movies, metadata
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+// Stream passes every movie matching q to fn, in id order, as they are read
+// from the database, without loading them all in memory. It stops at the
+// first error returned by fn.
+func (m MovieModel) Stream(ctx context.Context, q MovieQuery, fn func(movie *Movie) error) error {
+	var args queryArgs
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM movies
+		WHERE %s
+		ORDER BY id ASC`,
+		q.columns(&args),
+		q.where(&args),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, streamContextTimeout)
 	defer cancel()
 
-	query_args := []interface{}{
-		title,
-		pq.Array(genres),
-		filters.limit(),
-		filters.offset(),
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	rows, err := m.DB.QueryContext(ctx, query, query_args...)
+	for rows.Next() {
+		var (
+			movie          Movie
+			localizedTitle string
+		)
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.Status,
+			&movie.Images,
+			&localizedTitle,
+			&movie.Titles,
+			&movie.Releases,
+			&movie.ExternalIDs,
+			&movie.Tags,
+			&movie.Headline,
+			&movie.Relevance,
+		)
+		if err != nil {
+			return err
+		}
+
+		movie.setLocalizedTitle(localizedTitle)
+
+		if err = fn(&movie); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// queryMovies runs a movie listing query selecting the total number of
+// matching records followed by the MovieQuery columns.
+func (m MovieModel) queryMovies(
+	ctx context.Context,
+	query string,
+	args ...interface{},
+) ([]*Movie, int, error) {
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, Metadata{}, err
+		return nil, 0, err
 	}
 	defer rows.Close()
 
 	var totalRecords int
 	movies := []*Movie{}
 	for rows.Next() {
-		var movie Movie
+		var (
+			movie          Movie
+			localizedTitle string
+		)
 
 		err := rows.Scan(
 			&totalRecords,
@@ -91,78 +383,134 @@ func (m MovieModel) GetAll(
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.Status,
+			&movie.Images,
+			&localizedTitle,
+			&movie.Titles,
+			&movie.Releases,
+			&movie.ExternalIDs,
+			&movie.Tags,
+			&movie.Headline,
+			&movie.Relevance,
 		)
 		if err != nil {
-			return nil, Metadata{}, err
+			return nil, 0, err
 		}
+
+		movie.setLocalizedTitle(localizedTitle)
 		movies = append(movies, &movie)
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, Metadata{}, err
+		return nil, 0, err
 	}
 
-	metadata := newMetadata(totalRecords, filters.Page, filters.PageSize)
-
-	return movies, metadata, nil
+	return movies, totalRecords, nil
 }
 
-func (m MovieModel) Get(id int64) (*Movie, error) {
+func (m MovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
-	query := ` 
-	SELECT id, created_at, title, year, runtime, genres, version
-	FROM movies
-	WHERE id = $1`
 
-	var movie Movie
+	query := `SELECT ` + movieColumns + ` FROM movies WHERE id = $1 AND deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, id).Scan(
-		&movie.ID,
-		&movie.CreatedAt,
-		&movie.Title,
-		&movie.Year,
-		&movie.Runtime,
-		pq.Array(&movie.Genres),
-		&movie.Version,
-	)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
-		default:
-			return nil, err
-		}
-	}
-
-	return &movie, nil
+	return scanMovie(m.DB.QueryRowContext(ctx, query, id))
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert creates the movie along with its external ids and first revision,
+// attributed to the given user. It returns ErrDuplicateExternalID when one
+// of the external ids already belongs to another movie.
+func (m MovieModel) Insert(ctx context.Context, movie *Movie, userID int64) error {
 	query := `
-	INSERT into movies (title, year, runtime, genres)
-	VALUES ($1, $2, $3, $4)
+	INSERT into movies (title, year, runtime, genres, status)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id, created_at, version
 	`
-	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres)}
+	args := []interface{}{movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status}
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	return m.DB.
-		QueryRowContext(ctx, query, args...).
-		Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.
+			QueryRowContext(ctx, query, args...).
+			Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+		if err != nil {
+			return err
+		}
+
+		for _, externalID := range movie.ExternalIDs {
+			if err = insertExternalID(ctx, tx, movie.ID, externalID); err != nil {
+				return err
+			}
+		}
+
+		return insertMovieRevision(ctx, tx, movie, userID)
+	})
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// InsertBatch creates many movies at once by copying them into a staging
+// table, recording the first revision of each, attributed to the given user.
+// The movies are not updated with their generated ids.
+func (m MovieModel) InsertBatch(ctx context.Context, movies []*Movie, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, batchContextTimeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		_, err := tx.ExecContext(ctx, `
+		CREATE TEMP TABLE movie_import (
+			title text, year integer, runtime integer, genres text[], status text
+		) ON COMMIT DROP`)
+		if err != nil {
+			return err
+		}
+
+		stmt, err := tx.PrepareContext(
+			ctx, pq.CopyIn("movie_import", "title", "year", "runtime", "genres", "status"),
+		)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, movie := range movies {
+			_, err = stmt.ExecContext(
+				ctx, movie.Title, movie.Year, movie.Runtime, pq.Array(movie.Genres), movie.Status,
+			)
+			if err != nil {
+				return err
+			}
+		}
+
+		if _, err = stmt.ExecContext(ctx); err != nil {
+			return err
+		}
+
+		query := `
+		WITH inserted AS (
+			INSERT INTO movies (title, year, runtime, genres, status)
+			SELECT title, year, runtime, genres, status FROM movie_import
+			RETURNING id, version, title, year, runtime, genres
+		)
+		INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, user_id)
+		SELECT id, version, title, year, runtime, genres, $1 FROM inserted`
+
+		_, err = tx.ExecContext(ctx, query, sql.NullInt64{Int64: userID, Valid: userID > 0})
+		return err
+	})
+}
+
+// Update saves the movie as a new version, recording a revision attributed
+// to the given user.
+func (m MovieModel) Update(ctx context.Context, movie *Movie, userID int64) error {
 	query := `
 	UPDATE movies
 	SET title=$1, year=$2, runtime=$3, genres=$4, version = version + 1
-	WHERE id = $5 and version = $6
+	WHERE id = $5 and version = $6 AND deleted_at IS NULL
 	RETURNING version
 	`
 
@@ -175,36 +523,43 @@ func (m MovieModel) Update(movie *Movie) error {
 		movie.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	err := m.DB.
-		QueryRowContext(ctx, query, args...).
-		Scan(&movie.Version)
-	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrEditConflict
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		err := tx.
+			QueryRowContext(ctx, query, args...).
+			Scan(&movie.Version)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrEditConflict
 
-		default:
-			return err
+			default:
+				return err
+			}
 		}
-	}
 
-	return nil
+		return insertMovieRevision(ctx, tx, movie, userID)
+	})
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete moves the movie to the trash, from which PurgeDeleted permanently
+// removes it once it has been there long enough. Unless version is 0, the
+// movie must still be at that version, or ErrEditConflict is returned.
+func (m MovieModel) Delete(ctx context.Context, id int64, version int32) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
-	query := `DELETE FROM movies WHERE id = $1`
+	query := `
+		UPDATE movies SET deleted_at = NOW()
+		WHERE id = $1 AND ($2 = 0 OR version = $2) AND deleted_at IS NULL`
 
-	ctx, cancel := context.WithTimeout(context.Background(), contextTimeout)
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	result, err := m.DB.ExecContext(ctx, query, id, version)
 	if err != nil {
 		return err
 	}
@@ -214,9 +569,151 @@ func (m MovieModel) Delete(id int64) error {
 		return err
 	}
 
-	if rowsAffected == 0 {
+	switch {
+	case rowsAffected == 0 && version != 0:
+		return ErrEditConflict
+	case rowsAffected == 0:
 		return ErrRecordNotFound
 	}
 
 	return nil
 }
+
+func (m MovieModel) GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error) {
+	query := fmt.Sprintf(`
+		SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, status, deleted_at
+		FROM movies
+		WHERE deleted_at IS NOT NULL
+		ORDER BY %s
+		LIMIT $1 OFFSET $2`, filters.OrderBy())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	movies := []*Movie{}
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.Status,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := newMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// Restore takes the movie out of the trash.
+func (m MovieModel) Restore(ctx context.Context, id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	UPDATE movies SET deleted_at = NULL
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING ` + movieColumns
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanMovie(m.DB.QueryRowContext(ctx, query, id))
+}
+
+// PurgeDeleted permanently removes the movies that were moved to the trash
+// before the given time, returning how many were removed and the storage keys
+// of their images, which are no longer referenced.
+func (m MovieModel) PurgeDeleted(ctx context.Context, before time.Time) (int64, []string, error) {
+	query := `
+	WITH purged AS (
+		DELETE FROM movies WHERE deleted_at < $1 RETURNING id
+	)
+	SELECT
+		(SELECT count(*) FROM purged),
+		array(
+			SELECT unnest(array[key, thumbnail_key])
+			FROM movie_images WHERE movie_id IN (SELECT id FROM purged)
+		)`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var (
+		purged int64
+		keys   []string
+	)
+
+	err := m.DB.QueryRowContext(ctx, query, before).Scan(&purged, pq.Array(&keys))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return purged, keys, nil
+}
+
+// Autocomplete returns the movies whose title best matches the typed text,
+// tolerating typos through trigram word similarity. Unpublished movies are
+// only suggested when publishedOnly is false.
+func (m MovieModel) Autocomplete(
+	ctx context.Context,
+	text string,
+	limit int,
+	publishedOnly bool,
+) ([]*MovieSuggestion, error) {
+	query := `
+	SELECT id, title, year
+	FROM movies
+	WHERE $1 <% title AND deleted_at IS NULL AND (status = 'published' OR NOT $3)
+	ORDER BY word_similarity($1, title) DESC, id ASC
+	LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, text, limit, publishedOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*MovieSuggestion{}
+	for rows.Next() {
+		var suggestion MovieSuggestion
+
+		if err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// MovieFieldSafelist lists the movie fields that can be requested, and
+// MovieIncludeSafelist the related data that can be included along.
+var (
+	MovieFieldSafelist = []string{
+		"id", "title", "original_title", "year", "runtime", "genres", "version", "status",
+		"images", "headline", "relevance",
+	}
+	MovieIncludeSafelist = []string{"titles", "releases", "external_ids", "tags"}
+)
+
+// Projection selects the fields of the resources in a response, along with
+// the related data to include. Every field is selected when Fields is empty,
+// including whatever related data the resources were loaded with.
+type Projection struct {
+	Fields          []string
+	FieldSafelist   []string
+	Include         []string
+	IncludeSafelist []string
+}
+
+func (p Projection) Validate(v *validator.Validator) {
+	for _, field := range p.Fields {
+		v.Check(validator.In(field, p.FieldSafelist...), "fields", "invalid field "+field)
+	}
+	v.Check(validator.Unique(p.Fields), "fields", "must not contain duplicate values")
+
+	for _, include := range p.Include {
+		v.Check(validator.In(include, p.IncludeSafelist...), "include", "invalid include value "+include)
+	}
+	v.Check(validator.Unique(p.Include), "include", "must not contain duplicate values")
+}
+
+// HasField reports whether the field is selected.
+func (p Projection) HasField(field string) bool {
+	return len(p.Fields) == 0 || validator.In(field, p.Fields...)
+}
+
+// Includes reports whether the related data is included.
+func (p Projection) Includes(include string) bool {
+	return validator.In(include, p.Include...)
+}
+
+// Apply returns v restricted to the selected fields and the included related
+// data, using their JSON names. v is either a pointer to a struct or a slice
+// of them, which are turned into maps of their JSON encoded fields. It is
+// returned as is when every field is selected.
+func (p Projection) Apply(v any) (any, error) {
+	if len(p.Fields) == 0 {
+		return v, nil
+	}
+
+	value := reflect.ValueOf(v)
+
+	if value.Kind() == reflect.Slice {
+		projected := make([]map[string]json.RawMessage, value.Len())
+		for i := range projected {
+			var err error
+			if projected[i], err = p.project(value.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+		return projected, nil
+	}
+
+	return p.project(v)
+}
+
+func (p Projection) project(v any) (map[string]json.RawMessage, error) {
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var projected map[string]json.RawMessage
+	if err := json.Unmarshal(js, &projected); err != nil {
+		return nil, err
+	}
+
+	for name := range projected {
+		if !(p.HasField(name) || p.Includes(name)) {
+			delete(projected, name)
+		}
+	}
+
+	return projected, nil
+}
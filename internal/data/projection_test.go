@@ -0,0 +1,96 @@
+package data_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func TestProjectionApply(t *testing.T) {
+	movie := &data.Movie{
+		ID:      1,
+		Title:   "Moana",
+		Year:    2016,
+		Runtime: 107,
+		Genres:  []string{"animation"},
+		Titles:  data.MovieTitles{{Locale: "fr", Title: "Vaiana"}},
+	}
+
+	tests := []struct {
+		desc       string
+		projection data.Projection
+		expected   any
+	}{
+		{
+			desc:       "every field without a projection",
+			projection: data.Projection{},
+			expected:   movie,
+		},
+		{
+			desc:       "selected fields only",
+			projection: data.Projection{Fields: []string{"id", "title", "runtime"}},
+			expected: map[string]json.RawMessage{
+				"id": json.RawMessage(`1`), "title": json.RawMessage(`"Moana"`), "runtime": json.RawMessage(`"107 mins"`),
+			},
+		},
+		{
+			desc:       "empty fields are left out",
+			projection: data.Projection{Fields: []string{"id", "headline"}},
+			expected:   map[string]json.RawMessage{"id": json.RawMessage(`1`)},
+		},
+		{
+			desc:       "included related data is added",
+			projection: data.Projection{Fields: []string{"id"}, Include: []string{"titles"}},
+			expected: map[string]json.RawMessage{
+				"id":     json.RawMessage(`1`),
+				"titles": json.RawMessage(`[{"locale":"fr","title":"Vaiana"}]`),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			projected, err := tt.projection.Apply(movie)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tt.expected, projected); diff != "" {
+				t.Errorf("projection does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+
+	projected, err := data.Projection{Fields: []string{"id"}}.Apply([]*data.Movie{movie})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]map[string]json.RawMessage{{"id": json.RawMessage(`1`)}}, projected); diff != "" {
+		t.Errorf("projected slice does not match (-want, +got):\n%s", diff)
+	}
+}
+
+func TestProjectionValidate(t *testing.T) {
+	p := data.Projection{
+		Fields:          []string{"id", "id", "secret"},
+		FieldSafelist:   data.MovieFieldSafelist,
+		Include:         []string{"revisions"},
+		IncludeSafelist: data.MovieIncludeSafelist,
+	}
+
+	v := validator.New()
+	p.Validate(v)
+
+	expected := map[string]string{
+		"fields":  "invalid field secret",
+		"include": "invalid include value revisions",
+	}
+
+	if diff := cmp.Diff(expected, v.Errors); diff != "" {
+		t.Errorf("errors does not match (-want, +got):\n%s", diff)
+	}
+}
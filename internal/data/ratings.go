@@ -0,0 +1,80 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// likedRating is the lowest rating counted as liking a movie when looking
+// for similar and recommended movies.
+const likedRating = 7
+
+type MovieRating struct {
+	MovieID   int64     `json:"movie_id"`
+	Rating    int       `json:"rating"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ValidateMovieRating(v *validator.Validator, r *MovieRating) {
+	v.Check(r.Rating != 0, "rating", "must be provided")
+	v.Check(r.Rating >= 1 && r.Rating <= 10, "rating", "must be between 1 and 10")
+}
+
+type MovieRatingModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// Upsert sets the rating the user gives to the movie, replacing the one they
+// gave before.
+func (m MovieRatingModel) Upsert(ctx context.Context, userID int64, r *MovieRating) error {
+	query := `
+	INSERT INTO movie_ratings (user_id, movie_id, rating)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, movie_id) DO UPDATE SET
+		rating = EXCLUDED.rating,
+		updated_at = NOW()
+	RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, userID, r.MovieID, r.Rating).Scan(&r.UpdatedAt)
+}
+
+func (m MovieRatingModel) Get(ctx context.Context, userID, movieID int64) (*MovieRating, error) {
+	query := `
+	SELECT movie_id, rating, updated_at
+	FROM movie_ratings
+	WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var r MovieRating
+
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(&r.MovieID, &r.Rating, &r.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+func (m MovieRatingModel) Delete(ctx context.Context, userID, movieID int64) error {
+	query := `DELETE FROM movie_ratings WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return execOne(ctx, m.DB, query, userID, movieID)
+}
@@ -0,0 +1,45 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func TestValidateMovieRating(t *testing.T) {
+	tests := []struct {
+		desc     string
+		rating   int
+		expected map[string]string
+	}{
+		{
+			desc:     "valid rating",
+			rating:   7,
+			expected: map[string]string{},
+		},
+		{
+			desc:     "missing rating",
+			rating:   0,
+			expected: map[string]string{"rating": "must be provided"},
+		},
+		{
+			desc:     "rating out of range",
+			rating:   11,
+			expected: map[string]string{"rating": "must be between 1 and 10"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateMovieRating(v, &data.MovieRating{MovieID: 1, Rating: tt.rating})
+
+			if diff := cmp.Diff(tt.expected, v.Errors); diff != "" {
+				t.Errorf("errors does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package data
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// The score of a similar movie weighs the genres it shares with the movie,
+// how close their years are and how many of the users who liked the movie
+// also liked it.
+const (
+	similarGenresWeight   = 0.5
+	similarYearWeight     = 0.2
+	similarCoRatingWeight = 0.3
+
+	// similarYearSpan is the number of years apart past which the years of
+	// two movies no longer count as close.
+	similarYearSpan = 20
+)
+
+// The score of a recommended movie weighs how much the user likes its
+// genres, how many of the users who liked the same movies as them also
+// liked it and its average rating.
+const (
+	recommendedGenresWeight   = 0.4
+	recommendedCoRatingWeight = 0.4
+	recommendedRatingWeight   = 0.2
+)
+
+// GetSimilar lists the movies matching the scope of q that share genres with
+// the movie, or were liked by the same users, ranked by their similarity,
+// which is set as their relevance.
+func (m MovieModel) GetSimilar(
+	ctx context.Context,
+	movie *Movie,
+	q MovieQuery,
+	filters Filters,
+) ([]*Movie, Metadata, error) {
+	var args queryArgs
+
+	id := args.add(movie.ID)
+	genres := args.add(pq.Array(movie.Genres)) + "::text[]"
+
+	jaccard := fmt.Sprintf(`(
+		(SELECT count(*) FROM (SELECT unnest(genres) INTERSECT SELECT unnest(%[1]s)) AS shared)::float8 /
+		(SELECT count(*) FROM (SELECT unnest(genres) UNION SELECT unnest(%[1]s)) AS combined)
+	)`, genres)
+	yearProximity := fmt.Sprintf(
+		"greatest(0, 1 - abs(year - %s) / %d.0)",
+		args.add(movie.Year), similarYearSpan,
+	)
+	score := fmt.Sprintf(
+		"%g * %s + %g * %s + %g * COALESCE(co.share, 0)",
+		similarGenresWeight, jaccard,
+		similarYearWeight, yearProximity,
+		similarCoRatingWeight,
+	)
+
+	query := fmt.Sprintf(`
+		WITH likers AS (
+			SELECT user_id FROM movie_ratings WHERE movie_id = %[1]s AND rating >= %[2]d
+		), co_ratings AS (
+			SELECT r.movie_id, count(*)::float8 / (SELECT count(*) FROM likers) AS share
+			FROM movie_ratings r JOIN likers l ON l.user_id = r.user_id
+			WHERE r.movie_id <> %[1]s AND r.rating >= %[2]d
+			GROUP BY r.movie_id
+		)
+		SELECT count(*) OVER(), %[3]s, '' AS headline, %[4]s AS relevance
+		FROM movies LEFT JOIN co_ratings co ON co.movie_id = movies.id
+		WHERE %[5]s AND id <> %[1]s AND (genres && %[6]s OR co.share IS NOT NULL)
+		ORDER BY %[7]s
+		LIMIT %[8]s OFFSET %[9]s`,
+		id, likedRating,
+		q.baseColumns(&args), score, q.scope(&args), genres,
+		filters.OrderBy(), args.add(filters.limit()), args.add(filters.offset()),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	movies, totalRecords, err := m.queryMovies(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return movies, newMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
+
+// GetRecommendations lists the movies matching the scope of q that the user
+// has not rated, ranked by how likely they are to like them, which is set as
+// their relevance. Users who have not liked any movie yet get the best rated
+// movies.
+func (m MovieModel) GetRecommendations(
+	ctx context.Context,
+	userID int64,
+	q MovieQuery,
+	filters Filters,
+) ([]*Movie, Metadata, error) {
+	var args queryArgs
+
+	user := args.add(userID)
+
+	affinity := `COALESCE(
+		(SELECT sum(weight) FROM genre_weights WHERE genre = ANY(movies.genres)), 0
+	) / greatest(cardinality(movies.genres), 1)`
+	score := fmt.Sprintf(
+		"%g * %s + %g * COALESCE(co.share, 0) + %g * COALESCE(a.average, 0)",
+		recommendedGenresWeight, affinity,
+		recommendedCoRatingWeight,
+		recommendedRatingWeight,
+	)
+
+	query := fmt.Sprintf(`
+		WITH liked AS (
+			SELECT m.id, m.genres
+			FROM movie_ratings r JOIN movies m ON m.id = r.movie_id
+			WHERE r.user_id = %[1]s AND r.rating >= %[2]d
+		), genre_weights AS (
+			SELECT genre, count(*)::float8 / (SELECT count(*) FROM liked) AS weight
+			FROM liked CROSS JOIN unnest(liked.genres) AS genre
+			GROUP BY genre
+		), neighbours AS (
+			SELECT DISTINCT r.user_id
+			FROM movie_ratings r JOIN liked l ON l.id = r.movie_id
+			WHERE r.user_id <> %[1]s AND r.rating >= %[2]d
+		), co_ratings AS (
+			SELECT r.movie_id, count(*)::float8 / (SELECT count(*) FROM neighbours) AS share
+			FROM movie_ratings r JOIN neighbours n ON n.user_id = r.user_id
+			WHERE r.rating >= %[2]d
+			GROUP BY r.movie_id
+		), averages AS (
+			SELECT movie_id, avg(rating)::float8 / 10 AS average
+			FROM movie_ratings
+			GROUP BY movie_id
+		)
+		SELECT count(*) OVER(), %[3]s, '' AS headline, %[4]s AS relevance
+		FROM movies
+		LEFT JOIN co_ratings co ON co.movie_id = movies.id
+		LEFT JOIN averages a ON a.movie_id = movies.id
+		WHERE %[5]s AND NOT EXISTS (
+			SELECT 1 FROM movie_ratings seen WHERE seen.user_id = %[1]s AND seen.movie_id = movies.id
+		)
+		ORDER BY %[6]s
+		LIMIT %[7]s OFFSET %[8]s`,
+		user, likedRating,
+		q.baseColumns(&args), score, q.scope(&args),
+		filters.OrderBy(), args.add(filters.limit()), args.add(filters.offset()),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	movies, totalRecords, err := m.queryMovies(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+
+	return movies, newMetadata(totalRecords, filters.Page, filters.PageSize), nil
+}
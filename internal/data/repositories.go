@@ -0,0 +1,131 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// The repositories are the operations of the models that the handlers depend
+// on. The *Model types implement them on PostgreSQL, and the memory models
+// returned by NewMemoryModels implement them in memory, for tests.
+
+type MovieRepository interface {
+	WithTx(ctx context.Context, fn func(tx MovieRepository) error) error
+
+	Get(ctx context.Context, id int64) (*Movie, error)
+	GetAll(ctx context.Context, q MovieQuery, filters Filters) ([]*Movie, Metadata, error)
+	Stream(ctx context.Context, q MovieQuery, fn func(movie *Movie) error) error
+	Insert(ctx context.Context, movie *Movie, userID int64) error
+	InsertBatch(ctx context.Context, movies []*Movie, userID int64) error
+	Update(ctx context.Context, movie *Movie, userID int64) error
+	Delete(ctx context.Context, id int64, version int32) error
+
+	GetAllDeleted(ctx context.Context, filters Filters) ([]*Movie, Metadata, error)
+	Restore(ctx context.Context, id int64) (*Movie, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, []string, error)
+
+	Autocomplete(ctx context.Context, text string, limit int, publishedOnly bool) ([]*MovieSuggestion, error)
+	Facets(ctx context.Context, q MovieQuery, facets []string) (Facets, error)
+	Stats(ctx context.Context, q MovieQuery) (*MovieStats, error)
+	GetSimilar(ctx context.Context, movie *Movie, q MovieQuery, filters Filters) ([]*Movie, Metadata, error)
+	GetRecommendations(
+		ctx context.Context,
+		userID int64,
+		q MovieQuery,
+		filters Filters,
+	) ([]*Movie, Metadata, error)
+
+	GetByExternalID(ctx context.Context, e ExternalID) (*Movie, error)
+	FindDuplicate(ctx context.Context, title string, year int32) (*Movie, error)
+	Merge(ctx context.Context, targetID, sourceID, userID int64) (*Movie, []string, error)
+
+	SubmitChange(ctx context.Context, change *MovieChange) error
+	SetStatus(ctx context.Context, movie *Movie, status string) error
+}
+
+type MovieRevisionRepository interface {
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRevision, error)
+	Get(ctx context.Context, movieID int64, version int32) (*MovieRevision, error)
+}
+
+type MovieImageRepository interface {
+	Upsert(ctx context.Context, movieID int64, image *MovieImage) ([]string, error)
+	Delete(ctx context.Context, movieID int64, kind string) ([]string, error)
+}
+
+type MovieTitleRepository interface {
+	Upsert(ctx context.Context, movieID int64, t *MovieTitle) error
+	Delete(ctx context.Context, movieID int64, locale string) error
+}
+
+type MovieReleaseRepository interface {
+	Upsert(ctx context.Context, movieID int64, r *MovieRelease) error
+	Delete(ctx context.Context, movieID int64, country string) error
+}
+
+type MovieExternalIDRepository interface {
+	Insert(ctx context.Context, movieID int64, e ExternalID) error
+	Delete(ctx context.Context, movieID int64, e ExternalID) error
+}
+
+type MovieChangeRepository interface {
+	Get(ctx context.Context, id int64) (*MovieChange, error)
+	GetAll(ctx context.Context, status string, filters Filters) ([]*MovieChange, Metadata, error)
+	Approve(ctx context.Context, id, reviewerID int64, comment string) (*MovieChange, *Movie, error)
+	Reject(ctx context.Context, id, reviewerID int64, comment string) (*MovieChange, error)
+}
+
+type MovieRatingRepository interface {
+	Upsert(ctx context.Context, userID int64, r *MovieRating) error
+	Get(ctx context.Context, userID, movieID int64) (*MovieRating, error)
+	Delete(ctx context.Context, userID, movieID int64) error
+}
+
+type TagRepository interface {
+	AddToMovie(ctx context.Context, movieID int64, names []string, userID int64, approve bool) error
+	RemoveFromMovie(ctx context.Context, movieID int64, name string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*Tag, error)
+	Autocomplete(ctx context.Context, prefix string, limit int) ([]*Tag, error)
+	GetAll(ctx context.Context, status string, filters Filters) ([]*Tag, Metadata, error)
+	SetStatus(ctx context.Context, id int64, status string) (*Tag, error)
+}
+
+type UserRepository interface {
+	Insert(ctx context.Context, user *User) error
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	Update(ctx context.Context, user *User) error
+	GetForToken(ctx context.Context, scope, tokenPlaintext string) (*User, error)
+}
+
+type TokenRepository interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+}
+
+type PermissionRepository interface {
+	GetAllForUser(ctx context.Context, userID int64) (Permissions, error)
+	AddForUser(ctx context.Context, userID int64, codes ...string) error
+}
+
+type ImportJobRepository interface {
+	Insert(ctx context.Context, job *ImportJob) error
+	Update(ctx context.Context, job *ImportJob) error
+	Get(ctx context.Context, id int64) (*ImportJob, error)
+}
+
+var (
+	_ MovieRepository           = MovieModel{}
+	_ MovieRevisionRepository   = MovieRevisionModel{}
+	_ MovieImageRepository      = MovieImageModel{}
+	_ MovieTitleRepository      = MovieTitleModel{}
+	_ MovieReleaseRepository    = MovieReleaseModel{}
+	_ MovieExternalIDRepository = MovieExternalIDModel{}
+	_ MovieChangeRepository     = MovieChangeModel{}
+	_ MovieRatingRepository     = MovieRatingModel{}
+	_ TagRepository             = TagModel{}
+	_ UserRepository            = UserModel{}
+	_ TokenRepository           = TokenModel{}
+	_ PermissionRepository      = PermissionModel{}
+	_ ImportJobRepository       = ImportJobModel{}
+)
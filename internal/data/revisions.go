@@ -0,0 +1,192 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MovieRevision is the snapshot of a movie as it was at a given version.
+type MovieRevision struct {
+	MovieID   int64     `json:"movie_id"`
+	Version   int32     `json:"version"`
+	Title     string    `json:"title"`
+	Year      int32     `json:"year"`
+	Runtime   Runtime   `json:"runtime"`
+	Genres    []string  `json:"genres"`
+	UserID    int64     `json:"user_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// RuntimeFormat is the format the runtime is written in, the default
+	// one when empty.
+	RuntimeFormat string `json:"-"`
+}
+
+func (r MovieRevision) MarshalJSON() ([]byte, error) {
+	type revision MovieRevision
+	return json.Marshal(struct {
+		revision
+		Runtime any `json:"runtime"`
+	}{revision(r), r.Runtime.Format(r.RuntimeFormat)})
+}
+
+// Apply copies the snapshot values onto the movie, leaving its id and
+// current version untouched.
+func (r *MovieRevision) Apply(movie *Movie) {
+	movie.Title = r.Title
+	movie.Year = r.Year
+	movie.Runtime = r.Runtime
+	movie.Genres = slices.Clone(r.Genres)
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// DiffMovieRevisions lists the fields whose value differs between the two
+// revisions, with their runtimes in the revisions' format.
+func DiffMovieRevisions(from, to *MovieRevision) []FieldChange {
+	changes := []FieldChange{}
+
+	if from.Title != to.Title {
+		changes = append(changes, FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+	if from.Year != to.Year {
+		changes = append(changes, FieldChange{Field: "year", From: from.Year, To: to.Year})
+	}
+	if from.Runtime != to.Runtime {
+		changes = append(changes, FieldChange{
+			Field: "runtime", From: from.Runtime.Format(from.RuntimeFormat), To: to.Runtime.Format(to.RuntimeFormat),
+		})
+	}
+	if !slices.Equal(from.Genres, to.Genres) {
+		changes = append(changes, FieldChange{Field: "genres", From: from.Genres, To: to.Genres})
+	}
+
+	return changes
+}
+
+// insertMovieRevision records the current state of the movie, made by the
+// given user, as part of the transaction that changed it.
+func insertMovieRevision(ctx context.Context, tx DBTX, movie *Movie, userID int64) error {
+	query := `
+	INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	args := []interface{}{
+		movie.ID,
+		movie.Version,
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		sql.NullInt64{Int64: userID, Valid: userID > 0},
+	}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+type MovieRevisionModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+func (m MovieRevisionModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieRevision, error) {
+	if movieID < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT movie_id, version, title, year, runtime, genres, user_id, created_at
+	FROM movie_revisions
+	WHERE movie_id = $1
+	ORDER BY version DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*MovieRevision{}
+	for rows.Next() {
+		revision, err := scanMovieRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every movie has at least the revision of its creation.
+	if len(revisions) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return revisions, nil
+}
+
+func (m MovieRevisionModel) Get(ctx context.Context, movieID int64, version int32) (*MovieRevision, error) {
+	if movieID < 1 || version < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT movie_id, version, title, year, runtime, genres, user_id, created_at
+	FROM movie_revisions
+	WHERE movie_id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	revision, err := scanMovieRevision(m.DB.QueryRowContext(ctx, query, movieID, version))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+func scanMovieRevision(row interface{ Scan(...any) error }) (*MovieRevision, error) {
+	var (
+		revision MovieRevision
+		userID   sql.NullInt64
+	)
+
+	err := row.Scan(
+		&revision.MovieID,
+		&revision.Version,
+		&revision.Title,
+		&revision.Year,
+		&revision.Runtime,
+		pq.Array(&revision.Genres),
+		&userID,
+		&revision.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	revision.UserID = userID.Int64
+
+	return &revision, nil
+}
@@ -0,0 +1,67 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestDiffMovieRevisions(t *testing.T) {
+	base := data.MovieRevision{
+		Title:   "Moana",
+		Year:    2016,
+		Runtime: 107,
+		Genres:  []string{"animation", "adventure"},
+	}
+
+	tests := []struct {
+		desc     string
+		update   func(r *data.MovieRevision)
+		expected []data.FieldChange
+	}{
+		{
+			desc:     "no changes between equal revisions",
+			update:   func(r *data.MovieRevision) {},
+			expected: []data.FieldChange{},
+		},
+		{
+			desc: "changed fields are listed",
+			update: func(r *data.MovieRevision) {
+				r.Year = 2017
+				r.Runtime = 108
+			},
+			expected: []data.FieldChange{
+				{Field: "year", From: int32(2016), To: int32(2017)},
+				{Field: "runtime", From: "107 mins", To: "108 mins"},
+			},
+		},
+		{
+			desc: "genres order is a change",
+			update: func(r *data.MovieRevision) {
+				r.Genres = []string{"adventure", "animation"}
+			},
+			expected: []data.FieldChange{
+				{
+					Field: "genres",
+					From:  []string{"animation", "adventure"},
+					To:    []string{"adventure", "animation"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			to := base
+			tt.update(&to)
+
+			got := data.DiffMovieRevisions(&base, &to)
+
+			if diff := cmp.Diff(tt.expected, got); diff != "" {
+				t.Errorf("changes does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -3,37 +3,137 @@ package data
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
 var ErrInvalidRuntimeFormat = errors.New("invalid runtime format")
 
+// Runtimes are written in one of these formats, "102 mins" being the
+// default.
+const (
+	RuntimeFormatMins    = "mins"
+	RuntimeFormatMinutes = "minutes"
+	RuntimeFormatHuman   = "human"
+	RuntimeFormatISO8601 = "iso8601"
+)
+
+var RuntimeFormats = []string{
+	RuntimeFormatMins, RuntimeFormatMinutes, RuntimeFormatHuman, RuntimeFormatISO8601,
+}
+
+var (
+	runtimeMinutesRX = regexp.MustCompile(`^(\d+)(?:\s*(?:mins?|minutes?))?$`)
+	runtimeHumanRX   = regexp.MustCompile(`^(?:(\d+)\s*h)?\s*(?:(\d+)\s*m)?$`)
+	runtimeISO8601RX = regexp.MustCompile(`^PT(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?$`)
+)
+
 type Runtime int32
 
+// MarshalJSON writes the runtime in the default format. The resources
+// holding runtimes, such as movies, write them in the format set on them.
 func (r Runtime) MarshalJSON() ([]byte, error) {
 	value := fmt.Sprintf("%d mins", r)
 	valueQuoted := strconv.Quote(value)
 	return []byte(valueQuoted), nil
 }
 
+// UnmarshalJSON accepts an integer number of minutes, or a string in any of
+// the formats ParseRuntime understands.
 func (r *Runtime) UnmarshalJSON(jsonValue []byte) error {
-	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
-	if err != nil {
-		return ErrInvalidRuntimeFormat
+	if i, err := strconv.ParseInt(string(jsonValue), 10, 32); err == nil {
+		*r = Runtime(i)
+		return nil
 	}
 
-	parts := strings.Split(unquotedJSONValue, " ")
-	if len(parts) != 2 || parts[1] != "mins" {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
 		return ErrInvalidRuntimeFormat
 	}
 
-	i, err := strconv.ParseInt(parts[0], 10, 32)
+	runtime, err := ParseRuntime(unquotedJSONValue)
 	if err != nil {
-		return ErrInvalidRuntimeFormat
+		return err
 	}
 
-	*r = Runtime(i)
+	*r = runtime
 
 	return nil
 }
+
+// ParseRuntime parses a number of minutes, such as "102" or "102 mins", a
+// duration in hours and minutes, such as "1h 42m", or an ISO 8601 duration
+// of whole minutes, such as "PT1H42M".
+func ParseRuntime(s string) (Runtime, error) {
+	s = strings.TrimSpace(s)
+
+	var hours, minutes, seconds string
+
+	if match := runtimeMinutesRX.FindStringSubmatch(s); match != nil {
+		minutes = match[1]
+	} else if match := runtimeHumanRX.FindStringSubmatch(s); match != nil && s != "" {
+		hours, minutes = match[1], match[2]
+	} else if match := runtimeISO8601RX.FindStringSubmatch(strings.ToUpper(s)); match != nil && len(s) > 2 {
+		hours, minutes, seconds = match[1], match[2], match[3]
+	} else {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	var total int64
+	for _, part := range []struct {
+		value   string
+		seconds int64
+	}{{hours, 3600}, {minutes, 60}, {seconds, 1}} {
+		if part.value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseInt(part.value, 10, 32)
+		if err != nil {
+			return 0, ErrInvalidRuntimeFormat
+		}
+		total += n * part.seconds
+	}
+
+	if total%60 != 0 || total/60 > math.MaxInt32 {
+		return 0, ErrInvalidRuntimeFormat
+	}
+
+	return Runtime(total / 60), nil
+}
+
+// Format returns the runtime in the given format, as a value to be encoded
+// to JSON. Unknown formats fall back to the default.
+func (r Runtime) Format(format string) any {
+	hours, minutes := r/60, r%60
+
+	switch format {
+	case RuntimeFormatMinutes:
+		return int32(r)
+
+	case RuntimeFormatHuman:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("%dm", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("%dh", hours)
+		default:
+			return fmt.Sprintf("%dh %dm", hours, minutes)
+		}
+
+	case RuntimeFormatISO8601:
+		switch {
+		case hours == 0:
+			return fmt.Sprintf("PT%dM", minutes)
+		case minutes == 0:
+			return fmt.Sprintf("PT%dH", hours)
+		default:
+			return fmt.Sprintf("PT%dH%dM", hours, minutes)
+		}
+
+	default:
+		return fmt.Sprintf("%d mins", r)
+	}
+}
@@ -0,0 +1,86 @@
+package data_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestRuntimeUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		desc     string
+		input    string
+		expected data.Runtime
+		err      error
+	}{
+		{desc: "minutes string", input: `"102 mins"`, expected: 102},
+		{desc: "singular unit", input: `"102 min"`, expected: 102},
+		{desc: "plain integer", input: `102`, expected: 102},
+		{desc: "hours and minutes", input: `"1h 42m"`, expected: 102},
+		{desc: "hours only", input: `"2h"`, expected: 120},
+		{desc: "iso 8601 duration", input: `"PT1H42M"`, expected: 102},
+		{desc: "iso 8601 whole minutes in seconds", input: `"PT6120S"`, expected: 102},
+		{desc: "iso 8601 partial minute", input: `"PT1H42M30S"`, err: data.ErrInvalidRuntimeFormat},
+		{desc: "fractional number", input: `102.5`, err: data.ErrInvalidRuntimeFormat},
+		{desc: "unknown unit", input: `"102 secs"`, err: data.ErrInvalidRuntimeFormat},
+		{desc: "empty string", input: `""`, err: data.ErrInvalidRuntimeFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			var got data.Runtime
+			err := json.Unmarshal([]byte(tt.input), &got)
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestMovieMarshalJSON(t *testing.T) {
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: data.RuntimeFormatMins, expected: `"107 mins"`},
+		{format: data.RuntimeFormatMinutes, expected: `107`},
+		{format: data.RuntimeFormatHuman, expected: `"1h 47m"`},
+		{format: data.RuntimeFormatISO8601, expected: `"PT1H47M"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			movie := &data.Movie{
+				ID: 1, Title: "Moana", Year: 2016, Runtime: 107, Genres: []string{"animation"}, RuntimeFormat: tt.format,
+			}
+
+			got, err := json.Marshal(map[string]any{"movie": movie})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			expected := `{"movie":{"id":1,"title":"Moana","year":2016,` +
+				`"genres":["animation"],"version":0,"status":"","runtime":` + tt.expected + `}}`
+
+			if diff := cmp.Diff(expected, string(got)); diff != "" {
+				t.Errorf("JSON does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+// TextSearchConfigs maps the languages accepted by movie search to their
+// PostgreSQL text search configuration.
+var TextSearchConfigs = map[string]string{
+	"simple": "simple",
+	"da":     "danish",
+	"de":     "german",
+	"en":     "english",
+	"es":     "spanish",
+	"fi":     "finnish",
+	"fr":     "french",
+	"hu":     "hungarian",
+	"it":     "italian",
+	"nl":     "dutch",
+	"no":     "norwegian",
+	"pt":     "portuguese",
+	"ro":     "romanian",
+	"ru":     "russian",
+	"sv":     "swedish",
+	"tr":     "turkish",
+}
+
+// MovieQuery holds the criteria used to select movies in listings.
+type MovieQuery struct {
+	Title  string
+	Genres []string
+
+	// Tags restricts the movies to those with approved tags matching all of
+	// them, or any of them when TagMatch is TagMatchAny.
+	Tags     []string
+	TagMatch string
+
+	// Search uses web search syntax, supporting quoted phrases, "or" and
+	// negation with "-". Its last word also matches as a prefix so results
+	// can be shown as the user types.
+	Search   string
+	Language string
+
+	// Fuzzy matches Title and Search by trigram similarity instead of
+	// full-text search, tolerating typos.
+	Fuzzy bool
+
+	// Locales lists the preferred locales of the movie titles, most
+	// preferred first.
+	Locales []string
+
+	// Statuses restricts the movies to those with one of the statuses, when
+	// not empty.
+	Statuses []string
+
+	// Projection leaves the related data of the fields that are not selected
+	// out of the query, and adds the included related data.
+	Projection Projection
+}
+
+func ValidateMovieQuery(v *validator.Validator, q MovieQuery) {
+	v.Check(len(q.Search) <= 500, "search", "must not be longer than 500 bytes")
+
+	_, ok := TextSearchConfigs[q.Language]
+	v.Check(ok, "lang", "unsupported language")
+
+	for _, status := range q.Statuses {
+		v.Check(validator.In(status, MovieStatuses...), "status", "unsupported status "+status)
+	}
+
+	for _, tag := range q.Tags {
+		ValidateTagName(v, "tags", tag)
+	}
+	v.Check(validator.Unique(q.Tags), "tags", "must not contain duplicate values")
+	v.Check(
+		q.TagMatch == "" || validator.In(q.TagMatch, TagMatchAll, TagMatchAny),
+		"tags_match",
+		"must be all or any",
+	)
+}
+
+func (q MovieQuery) textSearchConfig() string {
+	if config, ok := TextSearchConfigs[q.Language]; ok {
+		return config
+	}
+	return "simple"
+}
+
+// columns returns the movie columns selected by listing queries, which
+// include the images, the localized title, the related data and the search
+// headline and relevance.
+func (q MovieQuery) columns(args *queryArgs) string {
+	columns := q.baseColumns(args)
+
+	if q.Fuzzy {
+		return fmt.Sprintf(
+			"%s, '' AS headline, word_similarity(%s, title) AS relevance",
+			columns, args.add(q.fuzzyText()),
+		)
+	}
+
+	if q.Search == "" {
+		return columns + ", '' AS headline, 0 AS relevance"
+	}
+
+	config := q.textSearchConfig()
+	tsquery := q.tsquery(config, args)
+
+	headline := "''"
+	if q.Projection.HasField("headline") {
+		headline = fmt.Sprintf(
+			"ts_headline('%s', title, %s, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true')",
+			config, tsquery,
+		)
+	}
+
+	return fmt.Sprintf(
+		`%s,
+		%s AS headline,
+		ts_rank(to_tsvector('%s', title), %s) AS relevance`,
+		columns, headline, config, tsquery,
+	)
+}
+
+// baseColumns returns the movie columns selected by listing queries up to
+// the included related data, leaving out the search headline and relevance.
+func (q MovieQuery) baseColumns(args *queryArgs) string {
+	p := q.Projection
+
+	images := "NULL AS images"
+	if p.HasField("images") {
+		images = movieImagesColumn
+	}
+
+	localizedTitle := "'' AS localized_title"
+	if p.HasField("title") || p.HasField("original_title") {
+		localizedTitle = localizedTitleColumn(q.Locales, args)
+	}
+
+	related := []string{"NULL AS titles", "NULL AS releases", "NULL AS external_ids", "NULL AS tags"}
+	if p.Includes("titles") {
+		related[0] = movieTitlesColumn
+	}
+	if p.Includes("releases") {
+		related[1] = movieReleasesColumn
+	}
+	if p.Includes("external_ids") {
+		related[2] = movieExternalIDsColumn
+	}
+	if p.Includes("tags") {
+		related[3] = movieTagsColumn
+	}
+
+	return fmt.Sprintf(
+		"id, created_at, title, year, runtime, genres, version, status, %s, %s, %s",
+		images, localizedTitle, strings.Join(related, ", "),
+	)
+}
+
+// scope returns the conditions limiting q to the movies that are not
+// deleted and have one of its statuses.
+func (q MovieQuery) scope(args *queryArgs) string {
+	scope := "deleted_at IS NULL"
+	if len(q.Statuses) > 0 {
+		scope += fmt.Sprintf(" AND status = ANY(%s)", args.add(pq.Array(q.Statuses)))
+	}
+	return scope
+}
+
+// where returns the conditions matched by the movies of q, adding the values
+// it references to args.
+func (q MovieQuery) where(args *queryArgs) string {
+	genres := args.add(pq.Array(q.Genres))
+	scope := q.scope(args)
+
+	if len(q.Tags) > 0 {
+		scope += " AND " + tagMatches(q.Tags, q.TagMatch, args)
+	}
+
+	if q.Fuzzy {
+		return fmt.Sprintf(
+			"%[1]s AND %[2]s <%% title AND (genres @> %[3]s OR %[3]s = '{}')",
+			scope, args.add(q.fuzzyText()), genres,
+		)
+	}
+
+	title := args.add(q.Title)
+	titleQuery := fmt.Sprintf("plainto_tsquery('simple', %s)", title)
+
+	conditions := []string{
+		scope,
+		fmt.Sprintf(
+			"(%s = '' OR to_tsvector('simple', title) @@ %s OR %s)",
+			title, titleQuery, alternateTitleMatches(titleQuery),
+		),
+		fmt.Sprintf("(genres @> %[1]s OR %[1]s = '{}')", genres),
+	}
+
+	if q.Search != "" {
+		config := q.textSearchConfig()
+
+		// Alternate titles are in many languages, so they are searched
+		// without language specific stemming.
+		conditions = append(conditions, fmt.Sprintf(
+			"(to_tsvector('%s', title) @@ %s OR %s)",
+			config, q.tsquery(config, args), alternateTitleMatches(q.tsquery("simple", args)),
+		))
+	}
+
+	return strings.Join(conditions, " AND ")
+}
+
+// alternateTitleMatches returns the condition matching the movies with an
+// alternate title that matches the simple configuration tsquery.
+func alternateTitleMatches(tsquery string) string {
+	return fmt.Sprintf(
+		"EXISTS (SELECT 1 FROM movie_titles t WHERE t.movie_id = movies.id AND to_tsvector('simple', t.title) @@ %s)",
+		tsquery,
+	)
+}
+
+func (q MovieQuery) fuzzyText() string {
+	return strings.TrimSpace(q.Title + " " + q.Search)
+}
+
+func (q MovieQuery) tsquery(config string, args *queryArgs) string {
+	search, prefix := splitPrefixTerm(q.Search)
+
+	tsquery := fmt.Sprintf("websearch_to_tsquery('%s', %s)", config, args.add(search))
+	if prefix != "" {
+		tsquery = fmt.Sprintf("(%s && to_tsquery('%s', %s))", tsquery, config, args.add(prefix+":*"))
+	}
+
+	return tsquery
+}
+
+var prefixTermRX = regexp.MustCompile(`(?:^|\s)([\p{L}\p{N}]+)$`)
+
+// splitPrefixTerm splits the trailing plain word off a web search so that
+// it can be matched as a prefix. Words that are part of a phrase, negated
+// or alternatives of an "or" are left in the search.
+func splitPrefixTerm(search string) (string, string) {
+	if strings.Count(search, `"`)%2 != 0 {
+		return search, ""
+	}
+
+	match := prefixTermRX.FindStringSubmatchIndex(search)
+	if match == nil {
+		return search, ""
+	}
+
+	rest := strings.TrimSpace(search[:match[2]])
+	if fields := strings.Fields(rest); len(fields) > 0 && strings.EqualFold(fields[len(fields)-1], "or") {
+		return search, ""
+	}
+
+	return rest, search[match[2]:match[3]]
+}
+
+// MovieSuggestion is a lightweight movie returned while the user types.
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year"`
+}
@@ -0,0 +1,41 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+)
+
+func TestSplitPrefixTerm(t *testing.T) {
+	tests := []struct {
+		desc           string
+		search         string
+		expectedRest   string
+		expectedPrefix string
+	}{
+		{desc: "empty", search: "", expectedRest: "", expectedPrefix: ""},
+		{desc: "whitespace only", search: "  \t ", expectedRest: "  \t ", expectedPrefix: ""},
+		{desc: "punctuation only", search: "?!-", expectedRest: "?!-", expectedPrefix: ""},
+		{desc: "single word", search: "moa", expectedRest: "", expectedPrefix: "moa"},
+		{desc: "trailing word", search: "disney moa", expectedRest: "disney", expectedPrefix: "moa"},
+		{desc: "trailing space", search: "disney moa ", expectedRest: "disney moa ", expectedPrefix: ""},
+		{desc: "trailing punctuation", search: "moana!", expectedRest: "moana!", expectedPrefix: ""},
+		{desc: "non-latin word", search: "le rôle", expectedRest: "le", expectedPrefix: "rôle"},
+		{desc: "closed phrase", search: `"the lion" ki`, expectedRest: `"the lion"`, expectedPrefix: "ki"},
+		{desc: "open phrase", search: `"the lion ki`, expectedRest: `"the lion ki`, expectedPrefix: ""},
+		{desc: "phrase ending", search: `"the lion"`, expectedRest: `"the lion"`, expectedPrefix: ""},
+		{desc: "negated word", search: "moana -mau", expectedRest: "moana -mau", expectedPrefix: ""},
+		{desc: "alternative", search: "moana or mau", expectedRest: "moana or mau", expectedPrefix: ""},
+		{desc: "alternative in capitals", search: "moana OR mau", expectedRest: "moana OR mau", expectedPrefix: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			rest, prefix := data.SplitPrefixTerm(tt.search)
+
+			if rest != tt.expectedRest || prefix != tt.expectedPrefix {
+				t.Errorf("expected (%q, %q), got (%q, %q)", tt.expectedRest, tt.expectedPrefix, rest, prefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MovieStats summarizes the movies matching a query.
+type MovieStats struct {
+	Total         int          `json:"total"`
+	RecentlyAdded RecentCounts `json:"recently_added"`
+	Genres        []FacetCount `json:"genres"`
+	Decades       []FacetCount `json:"decades"`
+	Runtime       RuntimeStats `json:"runtime"`
+	GeneratedAt   time.Time    `json:"generated_at"`
+}
+
+// RecentCounts holds the number of movies created in the last days.
+type RecentCounts struct {
+	Last7Days  int `json:"last_7_days"`
+	Last30Days int `json:"last_30_days"`
+}
+
+// RuntimeStats describes the distribution of the runtime of movies, which
+// is all zero when there are none.
+type RuntimeStats struct {
+	Min    Runtime `json:"min"`
+	Median Runtime `json:"median"`
+	P90    Runtime `json:"p90"`
+	Max    Runtime `json:"max"`
+
+	// Format is the format the runtimes are written in, the default one
+	// when empty.
+	Format string `json:"-"`
+}
+
+func (s RuntimeStats) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Min    any `json:"min"`
+		Median any `json:"median"`
+		P90    any `json:"p90"`
+		Max    any `json:"max"`
+	}{s.Min.Format(s.Format), s.Median.Format(s.Format), s.P90.Format(s.Format), s.Max.Format(s.Format)})
+}
+
+// Stats computes the statistics of the movies matching q.
+func (m MovieModel) Stats(ctx context.Context, q MovieQuery) (*MovieStats, error) {
+	var args queryArgs
+
+	query := fmt.Sprintf(`
+		SELECT
+			count(*),
+			count(*) FILTER (WHERE created_at >= NOW() - INTERVAL '7 days'),
+			count(*) FILTER (WHERE created_at >= NOW() - INTERVAL '30 days'),
+			COALESCE(min(runtime), 0),
+			COALESCE(percentile_disc(0.5) WITHIN GROUP (ORDER BY runtime), 0),
+			COALESCE(percentile_disc(0.9) WITHIN GROUP (ORDER BY runtime), 0),
+			COALESCE(max(runtime), 0),
+			NOW()
+		FROM movies
+		WHERE %s`,
+		q.where(&args),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	var stats MovieStats
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&stats.Total,
+		&stats.RecentlyAdded.Last7Days,
+		&stats.RecentlyAdded.Last30Days,
+		&stats.Runtime.Min,
+		&stats.Runtime.Median,
+		&stats.Runtime.P90,
+		&stats.Runtime.Max,
+		&stats.GeneratedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	facets, err := m.Facets(ctx, q, []string{"genres", "decade"})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Genres = facets["genres"]
+	stats.Decades = facets["decade"]
+
+	return &stats, nil
+}
@@ -0,0 +1,299 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+var (
+	ErrTagRejected = errors.New("tag rejected")
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// New tags are pending until a publisher approves them. Only approved tags
+// are shown on movies and can be used to filter them.
+const (
+	TagStatusPending  = "pending"
+	TagStatusApproved = "approved"
+	TagStatusRejected = "rejected"
+)
+
+var TagStatuses = []string{TagStatusPending, TagStatusApproved, TagStatusRejected}
+
+// Movies are filtered by tags either matching all of them or any of them.
+const (
+	TagMatchAll = "all"
+	TagMatchAny = "any"
+)
+
+const MaxMovieTags = 20
+
+var TagNameRX = regexp.MustCompile(`^[a-z0-9]+(?:-[a-z0-9]+)*$`)
+
+// movieTagsColumn selects the names of the approved tags of the movie.
+const movieTagsColumn = `COALESCE((
+	SELECT jsonb_agg(t.name ORDER BY t.name)
+	FROM movie_tags mt JOIN tags t ON t.id = mt.tag_id
+	WHERE mt.movie_id = movies.id AND t.status = 'approved'
+), '[]') AS tags`
+
+type Tag struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Uses      int       `json:"uses"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// MovieTags are the names of the tags of a movie.
+type MovieTags []string
+
+func (t *MovieTags) Scan(src any) error {
+	return scanJSON(src, t)
+}
+
+// NormalizeTag lowercases the tag name and joins its words with hyphens, so
+// that "Time Travel" and "time_travel" both become "time-travel".
+func NormalizeTag(name string) string {
+	name = strings.ToLower(strings.ReplaceAll(name, "_", " "))
+	return strings.Join(strings.Fields(name), "-")
+}
+
+func ValidateTagName(v *validator.Validator, key, name string) {
+	v.Check(name != "", key, "must not contain empty tags")
+	v.Check(len(name) <= 50, key, "must not contain tags longer than 50 bytes")
+	v.Check(TagNameRX.MatchString(name), key, "must only contain lowercase letters, digits and hyphens")
+}
+
+// tagMatches returns the condition matching the movies with the approved
+// tags, either all or any of them.
+func tagMatches(tags []string, match string, args *queryArgs) string {
+	names := args.add(pq.Array(tags)) + "::text[]"
+
+	if match == TagMatchAny {
+		return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM movie_tags mt JOIN tags t ON t.id = mt.tag_id
+			WHERE mt.movie_id = movies.id AND t.status = 'approved' AND t.name = ANY(%s)
+		)`, names)
+	}
+
+	return fmt.Sprintf(`(
+		SELECT count(*) FROM movie_tags mt JOIN tags t ON t.id = mt.tag_id
+		WHERE mt.movie_id = movies.id AND t.status = 'approved' AND t.name = ANY(%[1]s)
+	) = cardinality(%[1]s)`, names)
+}
+
+const tagColumns = `t.id, t.name, t.status, t.created_at,
+	(SELECT count(*) FROM movie_tags mt WHERE mt.tag_id = t.id) AS uses`
+
+func scanTag(row interface{ Scan(...any) error }, leading ...any) (*Tag, error) {
+	var tag Tag
+
+	dest := append(leading, &tag.ID, &tag.Name, &tag.Status, &tag.CreatedAt, &tag.Uses)
+	if err := row.Scan(dest...); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tag, nil
+}
+
+type TagModel struct {
+	DB      DBTX
+	Timeout time.Duration
+}
+
+// AddToMovie tags the movie with the named tags, creating those that do not
+// exist yet, attributed to the given user. New tags are approved right away
+// when approve is true, and pending otherwise. It fails with ErrTagRejected
+// when one of the tags was rejected, and with ErrTooManyTags when the movie
+// would have more than MaxMovieTags tags.
+func (m TagModel) AddToMovie(
+	ctx context.Context,
+	movieID int64,
+	names []string,
+	userID int64,
+	approve bool,
+) error {
+	status := TagStatusPending
+	if approve {
+		status = TagStatusApproved
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return inTx(ctx, m.DB, func(tx DBTX) error {
+		for _, name := range names {
+			var (
+				tagID     int64
+				tagStatus string
+			)
+
+			// The no-op update makes the existing tag returned on conflict.
+			err := tx.QueryRowContext(ctx, `
+			INSERT INTO tags (name, status, created_by)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id, status`, name, status, userID).Scan(&tagID, &tagStatus)
+			if err != nil {
+				return err
+			}
+
+			if tagStatus == TagStatusRejected {
+				return fmt.Errorf("%w: %s", ErrTagRejected, name)
+			}
+
+			_, err = tx.ExecContext(ctx, `
+			INSERT INTO movie_tags (movie_id, tag_id)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`, movieID, tagID)
+			if err != nil {
+				return err
+			}
+		}
+
+		var count int
+		err := tx.QueryRowContext(ctx, `SELECT count(*) FROM movie_tags WHERE movie_id = $1`, movieID).
+			Scan(&count)
+		if err != nil {
+			return err
+		}
+
+		if count > MaxMovieTags {
+			return ErrTooManyTags
+		}
+
+		return nil
+	})
+}
+
+func (m TagModel) RemoveFromMovie(ctx context.Context, movieID int64, name string) error {
+	query := `
+	DELETE FROM movie_tags USING tags
+	WHERE movie_tags.tag_id = tags.id AND movie_tags.movie_id = $1 AND tags.name = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return execOne(ctx, m.DB, query, movieID, name)
+}
+
+// GetAllForMovie returns every tag of the movie, including those that are
+// not approved yet, by name.
+func (m TagModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*Tag, error) {
+	query := `
+	SELECT ` + tagColumns + `
+	FROM tags t JOIN movie_tags mt ON mt.tag_id = t.id
+	WHERE mt.movie_id = $1
+	ORDER BY t.name`
+
+	return m.queryTags(ctx, query, movieID)
+}
+
+// Autocomplete returns the approved tags starting with the typed prefix, the
+// most used first.
+func (m TagModel) Autocomplete(ctx context.Context, prefix string, limit int) ([]*Tag, error) {
+	query := `
+	SELECT ` + tagColumns + `
+	FROM tags t
+	WHERE t.status = 'approved' AND t.name LIKE $1 || '%'
+	ORDER BY uses DESC, t.name ASC
+	LIMIT $2`
+
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+
+	return m.queryTags(ctx, query, escaped, limit)
+}
+
+// GetAll lists the tags with the given status, or all of them when status
+// is empty.
+func (m TagModel) GetAll(ctx context.Context, status string, filters Filters) ([]*Tag, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), %s
+	FROM tags t
+	WHERE t.status = $1 OR $1 = ''
+	ORDER BY %s
+	LIMIT $2 OFFSET $3`, tagColumns, filters.OrderBy())
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	var totalRecords int
+	tags := []*Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows, &totalRecords)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := newMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return tags, metadata, nil
+}
+
+// SetStatus approves or rejects the tag, or puts it back to pending.
+func (m TagModel) SetStatus(ctx context.Context, id int64, status string) (*Tag, error) {
+	query := `
+	WITH t AS (
+		UPDATE tags SET status = $2 WHERE id = $1
+		RETURNING id, name, status, created_at
+	)
+	SELECT ` + tagColumns + ` FROM t`
+
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	return scanTag(m.DB.QueryRowContext(ctx, query, id, status))
+}
+
+func (m TagModel) queryTags(ctx context.Context, query string, args ...any) ([]*Tag, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.Timeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
@@ -0,0 +1,71 @@
+package data_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/data"
+	"github.com/zmwilliam/greenlight/internal/validator"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "heist", expected: "heist"},
+		{name: "Time Travel", expected: "time-travel"},
+		{name: "time_travel", expected: "time-travel"},
+		{name: "  Based  on a   True Story ", expected: "based-on-a-true-story"},
+		{name: "   ", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := data.NormalizeTag(tt.name); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateTagName(t *testing.T) {
+	tests := []struct {
+		desc     string
+		name     string
+		expected map[string]string
+	}{
+		{
+			desc:     "valid tag",
+			name:     "time-travel",
+			expected: map[string]string{},
+		},
+		{
+			desc:     "empty tag",
+			name:     "",
+			expected: map[string]string{"tags": "must not contain empty tags"},
+		},
+		{
+			desc:     "invalid characters",
+			name:     "sci-fi!",
+			expected: map[string]string{"tags": "must only contain lowercase letters, digits and hyphens"},
+		},
+		{
+			desc:     "repeated hyphens",
+			name:     "sci--fi",
+			expected: map[string]string{"tags": "must only contain lowercase letters, digits and hyphens"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := validator.New()
+			data.ValidateTagName(v, "tags", tt.name)
+
+			if diff := cmp.Diff(tt.expected, v.Errors); diff != "" {
+				t.Errorf("errors does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"net/http"
+
+	// Register the decoders of the supported formats.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrTooLarge          = errors.New("image dimensions are too large")
+)
+
+// ContentTypes lists the media types of the images that can be decoded.
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Decode sniffs the content type of data and decodes it, refusing images
+// with more than maxPixels pixels before allocating memory for them.
+func Decode(data []byte, maxPixels int) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+
+	supported := false
+	for _, ct := range ContentTypes {
+		supported = supported || ct == contentType
+	}
+	if !supported {
+		return nil, contentType, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, contentType, err
+	}
+
+	if config.Width*config.Height > maxPixels {
+		return nil, contentType, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, contentType, err
+	}
+
+	return img, contentType, nil
+}
+
+// Fit returns the size of a width×height image scaled down, keeping its
+// aspect ratio, to fit within maxWidth×maxHeight. Sizes that already fit are
+// returned unchanged.
+func Fit(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+
+	scale := math.Min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height))
+
+	w := int(math.Round(float64(width) * scale))
+	h := int(math.Round(float64(height) * scale))
+
+	return max(w, 1), max(h, 1)
+}
+
+// Thumbnail returns img scaled down to fit within maxWidth×maxHeight. Each
+// pixel of the result is the average of the source pixels it covers.
+func Thumbnail(img image.Image, maxWidth, maxHeight int) *image.RGBA {
+	bounds := img.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	dw, dh := Fit(sw, sh, maxWidth, maxHeight)
+
+	// Drawing onto RGBA first lets the loop below read pixels directly,
+	// instead of going through the At method of every source format.
+	src := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	if dw == sw && dh == sh {
+		return src
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := span(y, sh, dh)
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := span(x, sw, dw)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := src.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// span returns the range of source coordinates covered by the destination
+// coordinate d when scaling src pixels down to dst.
+func span(d, src, dst int) (int, int) {
+	start := d * src / dst
+	end := (d + 1) * src / dst
+
+	return start, max(end, start+1)
+}
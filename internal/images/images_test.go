@@ -0,0 +1,105 @@
+package images_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/images"
+)
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		desc                string
+		width, height       int
+		maxWidth, maxHeight int
+		expected            [2]int
+	}{
+		{"already fits", 200, 300, 342, 513, [2]int{200, 300}},
+		{"limited by width", 2000, 3000, 342, 1000, [2]int{342, 513}},
+		{"limited by height", 3840, 2160, 1280, 360, [2]int{640, 360}},
+		{"never below one pixel", 10000, 1, 100, 100, [2]int{100, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			w, h := images.Fit(tt.width, tt.height, tt.maxWidth, tt.maxHeight)
+
+			if diff := cmp.Diff(tt.expected, [2]int{w, h}); diff != "" {
+				t.Errorf("size does not match (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		src.Set(0, y, color.RGBA{R: 255, A: 255})
+		src.Set(1, y, color.RGBA{B: 255, A: 255})
+		src.Set(2, y, color.RGBA{G: 200, A: 255})
+		src.Set(3, y, color.RGBA{G: 100, A: 255})
+	}
+
+	got := images.Thumbnail(src, 2, 2)
+
+	if diff := cmp.Diff(image.Rect(0, 0, 2, 1), got.Bounds()); diff != "" {
+		t.Fatalf("bounds does not match (-want, +got):\n%s", diff)
+	}
+
+	expected := []color.RGBA{
+		{R: 127, B: 127, A: 255},
+		{G: 150, A: 255},
+	}
+	for x, want := range expected {
+		if diff := cmp.Diff(want, got.RGBAAt(x, 0)); diff != "" {
+			t.Errorf("pixel %d does not match (-want, +got):\n%s", x, diff)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc      string
+		data      []byte
+		maxPixels int
+		err       error
+	}{
+		{desc: "decodes png", data: buf.Bytes(), maxPixels: 200},
+		{desc: "too many pixels", data: buf.Bytes(), maxPixels: 199, err: images.ErrTooLarge},
+		{desc: "not an image", data: []byte("%PDF-1.4"), maxPixels: 200, err: images.ErrUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			img, contentType, err := images.Decode(tt.data, tt.maxPixels)
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if contentType != "image/png" {
+				t.Errorf("expected image/png content type, got %s", contentType)
+			}
+			if img.Bounds().Dx() != 20 {
+				t.Errorf("expected width 20, got %d", img.Bounds().Dx())
+			}
+		})
+	}
+}
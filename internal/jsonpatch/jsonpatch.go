@@ -0,0 +1,408 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchMediaType = "application/merge-patch+json"
+	JSONPatchMediaType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch = errors.New("invalid patch")
+	ErrTestFailed   = errors.New("patch test failed")
+)
+
+// MergePatch applies an RFC 7396 JSON merge patch to the document.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := decode(patch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+	}
+
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+		} else {
+			t[key] = mergePatch(t[key], value)
+		}
+	}
+
+	return t
+}
+
+// Operation is a single RFC 6902 JSON patch operation. Value is left nil
+// when it is absent from the patch, so that it can be told apart from an
+// explicit null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies an RFC 6902 JSON patch to the document. Operations are
+// applied in order, and none of them are when any fails.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: patch must be an array of operations", ErrInvalidPatch)
+	}
+
+	root, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if root, err = apply(root, op); err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	return json.Marshal(root)
+}
+
+func apply(root any, op Operation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: %s operation requires a value", ErrInvalidPatch, op.Op)
+		}
+
+		value, err := decode(op.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPatch, err)
+		}
+
+		switch op.Op {
+		case "add":
+			return add(root, path, value)
+		case "replace":
+			if _, err := get(root, path); err != nil {
+				return nil, err
+			}
+			if root, err = remove(root, path); err != nil {
+				return nil, err
+			}
+			return add(root, path, value)
+		default:
+			current, err := get(root, path)
+			if err != nil {
+				return nil, err
+			}
+			if !equal(current, value) {
+				return nil, fmt.Errorf("%w: value at %q does not match", ErrTestFailed, op.Path)
+			}
+			return root, nil
+		}
+
+	case "remove":
+		return remove(root, path)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := get(root, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "copy" {
+			return add(root, path, clone(value))
+		}
+
+		if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+			return nil, fmt.Errorf("%w: cannot move %q into itself", ErrInvalidPatch, op.From)
+		}
+		if root, err = remove(root, from); err != nil {
+			return nil, err
+		}
+		return add(root, path, value)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: path %q must start with /", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func pathNotFound(path []string) error {
+	return fmt.Errorf("%w: path %q does not exist", ErrInvalidPatch, "/"+strings.Join(path, "/"))
+}
+
+// arrayIndex parses an array index token. The "-" token, which refers past
+// the last element, is only accepted when end is true.
+func arrayIndex(token string, length int, end bool) (int, bool) {
+	if token == "-" && end {
+		return length, true
+	}
+
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, false
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, false
+	}
+
+	if i > length || (i == length && !end) {
+		return 0, false
+	}
+
+	return i, true
+}
+
+func get(node any, path []string) (any, error) {
+	for i, token := range path {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[token]
+			if !ok {
+				return nil, pathNotFound(path[:i+1])
+			}
+			node = child
+		case []any:
+			idx, ok := arrayIndex(token, len(n), false)
+			if !ok {
+				return nil, pathNotFound(path[:i+1])
+			}
+			node = n[idx]
+		default:
+			return nil, pathNotFound(path[:i+1])
+		}
+	}
+
+	return node, nil
+}
+
+// add returns node with value added at path. Arrays are rebuilt, so the
+// result must replace node.
+func add(node any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch n := node.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			n[token] = value
+			return n, nil
+		}
+
+		child, ok := n[token]
+		if !ok {
+			return nil, pathNotFound(path[:1])
+		}
+
+		child, err := add(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = child
+		return n, nil
+
+	case []any:
+		idx, ok := arrayIndex(token, len(n), len(rest) == 0)
+		if !ok {
+			return nil, pathNotFound(path[:1])
+		}
+
+		if len(rest) == 0 {
+			n = append(n, nil)
+			copy(n[idx+1:], n[idx:])
+			n[idx] = value
+			return n, nil
+		}
+
+		child, err := add(n[idx], rest, value)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+
+	default:
+		return nil, pathNotFound(path[:1])
+	}
+}
+
+// remove returns node without the value at path. Arrays are rebuilt, so the
+// result must replace node.
+func remove(node any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove the whole document", ErrInvalidPatch)
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch n := node.(type) {
+	case map[string]any:
+		child, ok := n[token]
+		if !ok {
+			return nil, pathNotFound(path[:1])
+		}
+
+		if len(rest) == 0 {
+			delete(n, token)
+			return n, nil
+		}
+
+		child, err := remove(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		n[token] = child
+		return n, nil
+
+	case []any:
+		idx, ok := arrayIndex(token, len(n), false)
+		if !ok {
+			return nil, pathNotFound(path[:1])
+		}
+
+		if len(rest) == 0 {
+			return append(n[:idx], n[idx+1:]...), nil
+		}
+
+		child, err := remove(n[idx], rest)
+		if err != nil {
+			return nil, err
+		}
+		n[idx] = child
+		return n, nil
+
+	default:
+		return nil, pathNotFound(path[:1])
+	}
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func clone(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, child := range v {
+			m[key] = clone(child)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, child := range v {
+			s[i] = clone(child)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// equal compares two decoded JSON values, treating numbers as equal when
+// they have the same value regardless of how they are written.
+func equal(a, b any) bool {
+	switch x := a.(type) {
+	case map[string]any:
+		y, ok := b.(map[string]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, ok := y[key]
+			if !ok || !equal(value, other) {
+				return false
+			}
+		}
+		return true
+
+	case []any:
+		y, ok := b.([]any)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+
+	default:
+		return a == b
+	}
+}
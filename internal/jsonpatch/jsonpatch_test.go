@@ -0,0 +1,148 @@
+package jsonpatch_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/zmwilliam/greenlight/internal/jsonpatch"
+)
+
+func assertJSON(t *testing.T, expected string, got []byte) {
+	t.Helper()
+
+	var want, have any
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &have); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, have); diff != "" {
+		t.Errorf("documents does not match (-want, +got):\n%s", diff)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		desc     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{
+			desc:     "replaces and adds members",
+			doc:      `{"title": "Moana", "year": 2016}`,
+			patch:    `{"year": 2017, "runtime": "107 mins"}`,
+			expected: `{"title": "Moana", "year": 2017, "runtime": "107 mins"}`,
+		},
+		{
+			desc:     "null removes members",
+			doc:      `{"title": "Moana", "year": 2016}`,
+			patch:    `{"year": null}`,
+			expected: `{"title": "Moana"}`,
+		},
+		{
+			desc:     "arrays are replaced as a whole",
+			doc:      `{"genres": ["animation", "adventure"]}`,
+			patch:    `{"genres": ["comedy"]}`,
+			expected: `{"genres": ["comedy"]}`,
+		},
+		{
+			desc:     "nested objects are merged",
+			doc:      `{"a": {"b": 1, "c": 2}}`,
+			patch:    `{"a": {"c": null, "d": 3}}`,
+			expected: `{"a": {"b": 1, "d": 3}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := jsonpatch.MergePatch([]byte(tt.doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assertJSON(t, tt.expected, got)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"title": "Moana", "year": 2016, "genres": ["animation", "adventure"]}`
+
+	tests := []struct {
+		desc     string
+		patch    string
+		expected string
+		err      error
+	}{
+		{
+			desc:     "add appends to an array",
+			patch:    `[{"op": "add", "path": "/genres/-", "value": "family"}]`,
+			expected: `{"title": "Moana", "year": 2016, "genres": ["animation", "adventure", "family"]}`,
+		},
+		{
+			desc:     "add inserts into an array",
+			patch:    `[{"op": "add", "path": "/genres/0", "value": "family"}]`,
+			expected: `{"title": "Moana", "year": 2016, "genres": ["family", "animation", "adventure"]}`,
+		},
+		{
+			desc:     "remove deletes an array element",
+			patch:    `[{"op": "remove", "path": "/genres/0"}]`,
+			expected: `{"title": "Moana", "year": 2016, "genres": ["adventure"]}`,
+		},
+		{
+			desc:     "replace changes a member after a passing test",
+			patch:    `[{"op": "test", "path": "/year", "value": 2016.0}, {"op": "replace", "path": "/year", "value": 2017}]`,
+			expected: `{"title": "Moana", "year": 2017, "genres": ["animation", "adventure"]}`,
+		},
+		{
+			desc:     "move renames a member",
+			patch:    `[{"op": "move", "from": "/title", "path": "/name"}]`,
+			expected: `{"name": "Moana", "year": 2016, "genres": ["animation", "adventure"]}`,
+		},
+		{
+			desc:  "failing test aborts the patch",
+			patch: `[{"op": "replace", "path": "/year", "value": 2017}, {"op": "test", "path": "/title", "value": "Frozen"}]`,
+			err:   jsonpatch.ErrTestFailed,
+		},
+		{
+			desc:  "replace requires an existing path",
+			patch: `[{"op": "replace", "path": "/runtime", "value": "107 mins"}]`,
+			err:   jsonpatch.ErrInvalidPatch,
+		},
+		{
+			desc:  "array index must be in range",
+			patch: `[{"op": "remove", "path": "/genres/2"}]`,
+			err:   jsonpatch.ErrInvalidPatch,
+		},
+		{
+			desc:  "unknown operations are rejected",
+			patch: `[{"op": "rename", "path": "/title"}]`,
+			err:   jsonpatch.ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			got, err := jsonpatch.Apply([]byte(doc), []byte(tt.patch))
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("expected error %v, got %v", tt.err, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			assertJSON(t, tt.expected, got)
+		})
+	}
+}
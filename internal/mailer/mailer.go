@@ -12,12 +12,18 @@ import (
 //go:embed "templates"
 var templateFS embed.FS
 
-type Mailer struct {
+// Mailer sends emails rendered from the embedded templates. SMTP is the
+// implementation used by the API; tests can record emails instead.
+type Mailer interface {
+	Send(recipient, templateFile string, data interface{}) error
+}
+
+type SMTP struct {
 	client *mail.Client
 	sender string
 }
 
-func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
+func (m SMTP) Send(recipient, templateFile string, data interface{}) error {
 	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
 	if err != nil {
 		return err
@@ -56,7 +62,7 @@ func (m Mailer) Send(recipient, templateFile string, data interface{}) error {
 	return nil
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
+func New(host string, port int, username, password, sender string) SMTP {
 	client, err := mail.NewClient(
 		host,
 		mail.WithPort(port),
@@ -69,7 +75,7 @@ func New(host string, port int, username, password, sender string) Mailer {
 		panic(err)
 	}
 
-	return Mailer{
+	return SMTP{
 		client: client,
 		sender: sender,
 	}
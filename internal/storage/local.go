@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores files in a directory of the local filesystem. It also serves
+// them over HTTP, so it must be mounted at the path of its base URL.
+type Local struct {
+	root    string
+	baseURL string
+	files   http.Handler
+}
+
+func NewLocal(root, baseURL string) (*Local, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Local{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		files:   http.FileServer(http.Dir(root)),
+	}, nil
+}
+
+func (s *Local) path(key string) (string, error) {
+	key = path.Clean("/" + key)
+	if key == "/" {
+		return "", errors.New("storage: empty key")
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes the file to a temporary name first, so that it is never served
+// half written.
+func (s *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *Local) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+
+	return err
+}
+
+func (s *Local) URL(key string) string {
+	return s.baseURL + path.Clean("/"+key)
+}
+
+// ServeHTTP serves the stored files, with the base URL path already
+// stripped from the request. Directory listings are not served.
+func (s *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/") || strings.Contains(path.Base(r.URL.Path), ".upload-") {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.files.ServeHTTP(w, r)
+}
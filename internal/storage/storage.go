@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps uploaded files under slash separated keys and tells where
+// clients can download them from. Local is the filesystem implementation;
+// an S3 compatible store only needs to implement the same three methods.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS movies_title_english_idx;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS movie_revisions (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  version integer NOT NULL,
+  title text NOT NULL,
+  year integer NOT NULL,
+  runtime integer NOT NULL,
+  genres text[] NOT NULL,
+  user_id bigint REFERENCES users ON DELETE SET NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY(movie_id, version)
+);
+
+INSERT INTO movie_revisions (movie_id, version, title, year, runtime, genres, created_at)
+SELECT id, version, title, year, runtime, genres, created_at FROM movies;
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS import_jobs (
+  id bigserial PRIMARY KEY,
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  format text NOT NULL,
+  status text NOT NULL,
+  report jsonb NOT NULL DEFAULT '{}',
+  error text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS movie_images (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  kind text NOT NULL,
+  key text NOT NULL,
+  url text NOT NULL,
+  thumbnail_key text NOT NULL,
+  thumbnail_url text NOT NULL,
+  content_type text NOT NULL,
+  width integer NOT NULL,
+  height integer NOT NULL,
+  size bigint NOT NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  UNIQUE (movie_id, kind)
+);
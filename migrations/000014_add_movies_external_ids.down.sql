@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'movies:admin';
+DROP INDEX IF EXISTS movies_normalized_title_year_idx;
+DROP FUNCTION IF EXISTS normalize_title;
+DROP TABLE IF EXISTS movie_external_ids;
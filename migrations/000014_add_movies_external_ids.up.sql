@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS movie_external_ids (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  source text NOT NULL,
+  external_id text NOT NULL,
+  PRIMARY KEY (source, external_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_external_ids_movie_id_idx ON movie_external_ids (movie_id);
+
+CREATE OR REPLACE FUNCTION normalize_title(title text) RETURNS text
+LANGUAGE sql IMMUTABLE PARALLEL SAFE
+AS $$ SELECT lower(regexp_replace(title, '[^[:alnum:]]+', '', 'g')) $$;
+
+CREATE INDEX IF NOT EXISTS movies_normalized_title_year_idx ON movies (normalize_title(title), year)
+WHERE deleted_at IS NULL;
+
+INSERT INTO permissions (code) VALUES ('movies:admin');
@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE code = 'movies:publish';
+DROP TABLE IF EXISTS movie_changes;
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS movies_status_check;
+ALTER TABLE movies DROP COLUMN IF EXISTS status;
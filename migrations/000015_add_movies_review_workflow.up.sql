@@ -0,0 +1,24 @@
+ALTER TABLE movies ADD COLUMN IF NOT EXISTS status text NOT NULL DEFAULT 'published';
+ALTER TABLE movies ADD CONSTRAINT movies_status_check
+  CHECK (status IN ('draft', 'pending_review', 'published', 'archived'));
+
+CREATE TABLE IF NOT EXISTS movie_changes (
+  id bigserial PRIMARY KEY,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  kind text NOT NULL,
+  base_version integer NOT NULL,
+  title text NOT NULL,
+  year integer NOT NULL,
+  runtime integer NOT NULL,
+  genres text[] NOT NULL,
+  status text NOT NULL DEFAULT 'pending',
+  user_id bigint REFERENCES users ON DELETE SET NULL,
+  reviewer_id bigint REFERENCES users ON DELETE SET NULL,
+  comment text NOT NULL DEFAULT '',
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  reviewed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS movie_changes_status_idx ON movie_changes (status, id);
+
+INSERT INTO permissions (code) VALUES ('movies:publish');
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS movie_ratings (
+  user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  rating smallint NOT NULL CHECK (rating BETWEEN 1 AND 10),
+  updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_ratings_movie_id_idx ON movie_ratings (movie_id);
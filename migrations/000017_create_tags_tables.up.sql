@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS tags (
+  id bigserial PRIMARY KEY,
+  name text NOT NULL UNIQUE,
+  status text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'rejected')),
+  created_by bigint REFERENCES users ON DELETE SET NULL,
+  created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS tags_name_pattern_idx ON tags (name text_pattern_ops);
+CREATE INDEX IF NOT EXISTS tags_status_idx ON tags (status, id);
+
+CREATE TABLE IF NOT EXISTS movie_tags (
+  movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+  tag_id bigint NOT NULL REFERENCES tags ON DELETE CASCADE,
+  PRIMARY KEY (movie_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_tags_tag_id_idx ON movie_tags (tag_id);